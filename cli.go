@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/nottgy/github-sniffer/sniffer"
+)
+
+// runCLI scans users without the TUI and writes their results with
+// emitter. A streamEmitter gets the result of each user as soon as it is
+// done, in no particular order, and the result is dropped right after, so
+// big batches don't pile up in memory; they aren't kept in the history
+// either. Other emitters get them all at the end, in the order of users.
+// The return value is the process exit status: 1 if a scan failed, 2 if
+// some repos could not be scanned.
+func runCLI(users []string, emitter Emitter) int {
+	var repoDone func(sniffer.RepoError)
+	if verbose {
+		repoDone = printRepo
+	}
+
+	stream, streaming := emitter.(streamEmitter)
+	var out io.WriteCloser
+	if streaming {
+		var err error
+		if out, err = openOutput(); err != nil {
+			fmt.Fprintf(os.Stderr, "github-sniffer: %v\n", err)
+			return 1
+		}
+	}
+
+	// The scans run side by side and share the -concurrency limit.
+	type scanned struct {
+		i   int
+		msg tea.Msg
+	}
+	done := make(chan scanned)
+	for i, user := range users {
+		go func() {
+			done <- scanned{i, scan(context.Background(), user, nil, nil, nil, repoDone)}
+		}()
+	}
+
+	status := 0
+	var results []*sniffer.Result
+	if !streaming {
+		results = make([]*sniffer.Result, len(users))
+	}
+	for range users {
+		s := <-done
+		switch msg := s.msg.(type) {
+		case errMsg:
+			fmt.Fprintf(os.Stderr, "github-sniffer: %s: %v\n", msg.user, msg.err)
+			status = 1
+		case dataMsg:
+			r := sniffer.Result{User: msg.user, RenamedTo: msg.renamedTo, Contacts: msg.data, Failed: msg.failed, Stats: msg.stats, SampledFrom: msg.sampledFrom, CutShort: msg.cutShort}
+			if streaming {
+				if err := stream.EmitOne(out, r); err != nil {
+					fmt.Fprintf(os.Stderr, "github-sniffer: %v\n", err)
+					return 1
+				}
+			} else {
+				results[s.i] = &r
+			}
+			if verbose {
+				fmt.Fprintf(os.Stderr, "github-sniffer: %s: %v\n", msg.user, msg.stats)
+			}
+			if msg.sampledFrom > 0 && !quiet {
+				fmt.Fprintf(os.Stderr, "github-sniffer: %s: sampled %d of %d repos\n", msg.user, opts.Sample, msg.sampledFrom)
+			}
+			if len(msg.cutShort) > 0 && !quiet {
+				fmt.Fprintf(os.Stderr, "github-sniffer: %s: only the latest %d commits were read of %s\n", msg.user, opts.MaxCommits, strings.Join(msg.cutShort, ", "))
+			}
+			if msg.renamedTo != "" && !quiet {
+				fmt.Fprintf(os.Stderr, "github-sniffer: %s redirected to %s\n", msg.user, msg.renamedTo)
+			}
+			for _, f := range msg.failed {
+				if !quiet {
+					fmt.Fprintf(os.Stderr, "github-sniffer: %v\n", f)
+				}
+			}
+			if len(msg.failed) > 0 && status == 0 {
+				status = 2
+			}
+		}
+	}
+	if streaming {
+		if err := out.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "github-sniffer: %v\n", err)
+			return 1
+		}
+		return status
+	}
+
+	var finished []sniffer.Result
+	for _, r := range results {
+		if r != nil {
+			finished = append(finished, *r)
+		}
+	}
+	if len(finished) == 0 {
+		return status
+	}
+	addHistory(finished)
+	if err := writeResult(emitter, finished); err != nil {
+		fmt.Fprintf(os.Stderr, "github-sniffer: %v\n", err)
+		return 1
+	}
+	return status
+}
+
+// printRepo reports a finished repo on stderr, so -verbose scripted runs
+// can be watched while stdout is piped on.
+func printRepo(r sniffer.RepoError) {
+	if r.Err != nil {
+		fmt.Fprintf(os.Stderr, "failed %s: %v\n", r.Repo, r.Err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "scanned %s: %d emails\n", r.Repo, len(r.Contacts))
+}
+
+// runListRepos prints the full names of the repos of users that a scan
+// would walk, one per line, and returns the process exit status.
+func runListRepos(users []string) int {
+	status := 0
+	for _, user := range users {
+		repos, err := sniffer.ListRepos(context.Background(), user, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "github-sniffer: %s: %v\n", user, err)
+			status = 1
+			continue
+		}
+		for _, repo := range repos {
+			fmt.Println(repo)
+		}
+	}
+	return status
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/nottgy/github-sniffer/sniffer"
+)
+
+// maxHistory is how many past searches are kept, newest first.
+const maxHistory = 30
+
+// historyEntry is a past search and what it found.
+type historyEntry struct {
+	Users   []string        `json:"users"`
+	At      time.Time       `json:"at"`
+	Emails  int             `json:"emails"`
+	Results []historyResult `json:"results"`
+}
+
+// historyResult is the part of a sniffer.Result worth keeping; the
+// errors of failed repos don't survive JSON.
+type historyResult struct {
+	User      string            `json:"user"`
+	RenamedTo string            `json:"renamed_to,omitempty"`
+	Contacts  []sniffer.Contact `json:"contacts"`
+}
+
+func historyPath() (string, error) {
+	return configPath("history.json")
+}
+
+func readHistory() ([]historyEntry, error) {
+	p, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []historyEntry
+	return entries, json.Unmarshal(b, &entries)
+}
+
+func writeHistory(entries []historyEntry) error {
+	p, err := historyPath()
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return writeState(p, b)
+}
+
+// addHistory records a finished search of the users in rs. The history
+// only saves typing, so failing to update it is logged, not fatal.
+func addHistory(rs []sniffer.Result) {
+	if len(rs) == 0 {
+		return
+	}
+	e := historyEntry{At: time.Now()}
+	for _, r := range rs {
+		e.Users = append(e.Users, r.User)
+		e.Emails += len(r.Contacts)
+		e.Results = append(e.Results, historyResult{User: r.User, RenamedTo: r.RenamedTo, Contacts: r.Contacts})
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	entries, err := readHistory()
+	if err == nil {
+		entries = append([]historyEntry{e}, entries...)
+		err = writeHistory(entries[:min(len(entries), maxHistory)])
+	}
+	if err != nil {
+		opts.Logger.Warn("could not save the search history", "err", err)
+	}
+}
+
+// historyItem shows a past search in the history list.
+type historyItem struct{ historyEntry }
+
+func (h historyItem) Title() string { return strings.Join(h.Users, ", ") }
+func (h historyItem) Description() string {
+	return fmt.Sprintf("%s • %d emails", h.At.Local().Format("2006-01-02 15:04"), h.Emails)
+}
+func (h historyItem) FilterValue() string { return h.Title() }
+
+var historyKeys = []key.Binding{
+	key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "scan again")),
+	key.NewBinding(key.WithKeys("v"), key.WithHelp("v", "view saved results")),
+	key.NewBinding(key.WithKeys("D"), key.WithHelp("D", "clear history")),
+	key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "back")),
+}
+
+// openHistory switches to the list of past searches.
+func (m *model) openHistory() {
+	entries, err := readHistory()
+	items := make([]list.Item, len(entries))
+	for i, e := range entries {
+		items[i] = historyItem{e}
+	}
+	m.history = list.New(items, themedDelegate(), max(m.width, 60), max(m.height, 10))
+	m.history.Title = "Past searches"
+	styleList(&m.history)
+	m.history.SetStatusBarItemName("search", "searches")
+	m.history.AdditionalShortHelpKeys = func() []key.Binding { return historyKeys }
+	// Esc goes back to the search rather than quitting.
+	m.history.KeyMap.Quit.SetKeys("q")
+	if err != nil {
+		m.history.NewStatusMessage(errorStyle.Render(err.Error()))
+	}
+	m.isFinished = false
+	m.browsing = true
+}
+
+// updateHistory handles the keys of the history list.
+func (m *model) updateHistory(msg tea.KeyMsg) tea.Cmd {
+	if m.history.FilterState() == list.Filtering {
+		var cmd tea.Cmd
+		m.history, cmd = m.history.Update(msg)
+		return cmd
+	}
+
+	item, selected := m.history.SelectedItem().(historyItem)
+	switch msg.String() {
+	case "esc":
+		if m.history.FilterState() == list.FilterApplied {
+			break
+		}
+		m.browsing = false
+		return m.searchAgain()
+	case "enter":
+		if !selected {
+			return nil
+		}
+		m.browsing = false
+		m.inputs[0].SetValue(strings.Join(item.Users, ", "))
+		return m.beginScan(item.Users)
+	case "v":
+		if !selected {
+			return nil
+		}
+		if len(item.Results) == 0 {
+			// Older or hand-edited files can have searches without them.
+			return m.history.NewStatusMessage(errorStyle.Render("no saved results, enter scans again"))
+		}
+		m.browsing = false
+		m.scans = make([]userResult, len(item.Results))
+		for i, r := range item.Results {
+			m.scans[i] = userResult{user: r.User, renamedTo: r.RenamedTo, data: r.Contacts}
+		}
+		m.elapsed = 0
+		m.isFinished = true
+		m.results = newResultsTable()
+		m.showUser(0)
+		m.status = "saved " + item.At.Local().Format("2006-01-02 15:04")
+		return nil
+	case "D":
+		stateMu.Lock()
+		err := writeHistory(nil)
+		stateMu.Unlock()
+		if err != nil {
+			return m.history.NewStatusMessage(errorStyle.Render(err.Error()))
+		}
+		return m.history.SetItems(nil)
+	}
+
+	var cmd tea.Cmd
+	m.history, cmd = m.history.Update(msg)
+	return cmd
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// inputKeyMap lists the keys of the nickname screen for the ? overlay.
+type inputKeyMap struct {
+	Next, Prev, Submit, CursorMode, History, Help, Quit key.Binding
+}
+
+var inputKeys = inputKeyMap{
+	Next:       key.NewBinding(key.WithKeys("tab", "down"), key.WithHelp("tab/↓", "next field")),
+	Prev:       key.NewBinding(key.WithKeys("shift+tab", "up"), key.WithHelp("shift+tab/↑", "previous field")),
+	Submit:     key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "next field, or scan on [ Check ]")),
+	CursorMode: key.NewBinding(key.WithKeys("ctrl+r"), key.WithHelp("ctrl+r", "change cursor style")),
+	History:    key.NewBinding(key.WithKeys("ctrl+p"), key.WithHelp("ctrl+p", "past searches")),
+	Help:       key.NewBinding(key.WithKeys("?"), key.WithHelp("?", "toggle this help")),
+	Quit:       key.NewBinding(key.WithKeys("esc", "ctrl+c"), key.WithHelp("esc", "quit")),
+}
+
+func (k inputKeyMap) ShortHelp() []key.Binding { return []key.Binding{k.Submit, k.Help, k.Quit} }
+
+func (k inputKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Next, k.Prev, k.Submit},
+		{k.CursorMode, k.History, k.Help, k.Quit},
+	}
+}
+
+// resultKeyMap lists the keys of the results screen for the ? overlay.
+type resultKeyMap struct {
+	Move, Page, SwitchUser, Sort, Domains, Matrix, CopyOne, Copy, Search, History, Help, Quit key.Binding
+}
+
+var resultKeys = resultKeyMap{
+	Move:       key.NewBinding(key.WithKeys("up", "down"), key.WithHelp("↑/↓", "move")),
+	Page:       key.NewBinding(key.WithKeys("pgup", "pgdown"), key.WithHelp("pgup/pgdn", "page")),
+	SwitchUser: key.NewBinding(key.WithKeys("tab", "shift+tab"), key.WithHelp("tab/shift+tab", "switch user")),
+	Sort:       key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "sort by the next column")),
+	Domains:    key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "emails by domain")),
+	Matrix:     key.NewBinding(key.WithKeys("m"), key.WithHelp("m", "commits per repo")),
+	CopyOne:    key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "copy the highlighted email")),
+	Copy:       key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "copy all the emails")),
+	Search:     key.NewBinding(key.WithKeys("r", "/"), key.WithHelp("r or /", "search again")),
+	History:    key.NewBinding(key.WithKeys("h"), key.WithHelp("h", "past searches")),
+	Help:       key.NewBinding(key.WithKeys("?"), key.WithHelp("?", "toggle this help")),
+	Quit:       key.NewBinding(key.WithKeys("q", "esc", "ctrl+c"), key.WithHelp("q", "quit")),
+}
+
+func (k resultKeyMap) ShortHelp() []key.Binding { return []key.Binding{k.Copy, k.Help, k.Quit} }
+
+func (k resultKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Move, k.Page, k.SwitchUser, k.Sort, k.Domains, k.Matrix},
+		{k.CopyOne, k.Copy, k.Search, k.History, k.Help, k.Quit},
+	}
+}
+
+// newHelp returns the help renderer, in the colors of the rest of the UI.
+func newHelp() help.Model {
+	h := help.New()
+	h.Styles.FullKey = focusedStyle
+	h.Styles.FullDesc = helpStyle
+	h.Styles.FullSeparator = blurredStyle
+	h.Styles.ShortKey = focusedStyle
+	h.Styles.ShortDesc = helpStyle
+	h.Styles.ShortSeparator = blurredStyle
+	return h
+}
+
+// helpView draws the keys of the current screen in a box, centered when
+// the window size is known.
+func (m model) helpView() string {
+	var keys help.KeyMap = inputKeys
+	if m.isFinished {
+		keys = resultKeys
+	}
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(focusedStyle.GetForeground()).
+		Padding(1, 2).
+		Render("Keys\n\n" + m.help.FullHelpView(keys.FullHelp()) + "\n\n" + helpStyle.Render("? or esc to close"))
+	if m.width == 0 {
+		return box
+	}
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, box)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/nottgy/github-sniffer/sniffer"
+)
+
+// logLevel is the level chosen with -verbose and -quiet: warnings by
+// default.
+func logLevel() slog.Level {
+	switch {
+	case verbose:
+		return slog.LevelDebug
+	case quiet:
+		return slog.LevelError
+	}
+	return slog.LevelWarn
+}
+
+// newLogger returns the logger for the run and closes its file, if any,
+// with the returned func. Logs go to -log-file when given. Otherwise
+// scripted runs log to stderr; the TUI owns the terminal, so its logs go
+// to github-sniffer.log in the cache directory, and only with -verbose.
+func newLogger(interactive bool) (*slog.Logger, func() error, error) {
+	var w io.Writer = os.Stderr
+	path := logFile
+	if path == "" && interactive {
+		w = io.Discard
+		if verbose {
+			dir, err := sniffer.DefaultCacheDir()
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := os.MkdirAll(dir, 0o700); err != nil {
+				return nil, nil, err
+			}
+			path = filepath.Join(dir, "github-sniffer.log")
+		}
+	}
+
+	closeFn := func() error { return nil }
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, nil, err
+		}
+		w, closeFn = f, f.Close
+	}
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: logLevel()})), closeFn, nil
+}
+
+// logRepo logs the outcome of a repo at debug level, which is how the TUI
+// reports repos with -verbose.
+func logRepo(r sniffer.RepoError) {
+	if r.Err != nil {
+		opts.Logger.Debug("repo failed", "repo", r.Repo, "err", r.Err)
+		return
+	}
+	opts.Logger.Debug("repo scanned", "repo", r.Repo, "contacts", len(r.Contacts))
+}
@@ -4,222 +4,411 @@ package main
 // from the Bubbles component library.
 
 import (
+	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"runtime/debug"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
+	"github.com/atotto/clipboard"
 	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/table"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"golang.org/x/time/rate"
+
+	"github.com/nottgy/github-sniffer/sniffer"
 )
 
+// The styles of the -theme, set by applyTheme.
 var (
-	focusedStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
-	blurredStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
-	cursorStyle         = focusedStyle
+	focusedStyle        lipgloss.Style
+	blurredStyle        lipgloss.Style
+	cursorStyle         lipgloss.Style
 	noStyle             = lipgloss.NewStyle()
-	helpStyle           = blurredStyle
-	cursorModeHelpStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("244"))
+	helpStyle           lipgloss.Style
+	cursorModeHelpStyle lipgloss.Style
+	errorStyle          lipgloss.Style
 
-	focusedButton = focusedStyle.Render("[ Check ]")
-	blurredButton = fmt.Sprintf("[ %s ]", blurredStyle.Render("Check"))
+	focusedButton string
+	blurredButton string
 )
 
-// curl https://api.github.com/users/notTGY/repos
-// curl https://api.github.com/repos/notTGY/mojango/commits
-const baseRepos = "https://api.github.com/repos"
-const baseUsers = "https://api.github.com/users"
-
-type Author struct {
-	Email string `json:"email"`
-}
-type Commit struct {
-	Author Author `json:"author"`
-}
-type CommitDataPiece struct {
-	Commit Commit `json:"commit"`
-}
-
-type RepoDataPiece struct {
-	FullName string `json:"full_name"`
-}
+// version is stamped at build time with -ldflags "-X main.version=...".
+var version = "dev"
 
 type model struct {
 	focusIndex int
 	inputs     []textinput.Model
 	cursorMode cursor.Mode
+	// inputErr explains why the nicknames entered can't be scanned.
+	inputErr string
+
+	// login is the authenticated account being scanned with -self.
+	login string
+	// tier tells which rate limit the scans run under, "" until known.
+	tier string
 
 	isLoading  bool
 	isFinished bool
-	data       []string
-	err        error
+
+	// listing is set while the repos of pickUsers are listed for
+	// -pick-repos or -confirm-threshold, picking while they are chosen in
+	// picker, confirming while a scan of confirmRepos awaits a yes. listed
+	// keeps what the listing found for the scan to reuse, nil when the
+	// scan has to list the repos itself.
+	listing      bool
+	picking      bool
+	confirming   bool
+	confirmRepos int
+	pickUsers    []string
+	listed       map[string][]string
+	picker       list.Model
+	checked      map[string]bool
+	repoOwner    map[string]string
+
+	// browsing is set while the past searches in history are shown.
+	browsing bool
+	history  list.Model
+
+	// showHelp covers the input or results screen with its keys.
+	showHelp bool
+	help     help.Model
+
+	data   []sniffer.Contact
+	failed []sniffer.RepoError
+	err    error
+
+	// scans holds one result per scanned user, current is the one shown.
+	scans   []userResult
+	current int
+	pending int
+
+	// events carries the messages of the running scan, cancel stops it.
+	// cancelling is set once esc stopped it, until the partial results
+	// are in.
+	events     chan tea.Msg
+	cancel     context.CancelFunc
+	cancelling bool
+	spinner    spinner.Model
+	progress   progress.Model
+	done       map[string]int
+	total      map[string]int
+	budget     sniffer.Budget
+	// live collects the emails of every repo done so far, of all users,
+	// one per address; liveIndex maps addresses to their place in it.
+	live      []sniffer.Contact
+	liveIndex map[string]int
+	// repos lists the repos started so far, in the order they started;
+	// repoIndex maps full names to their place in it.
+	repos     []repoStatus
+	repoIndex map[string]int
+	// started is when the scan began, elapsed how long it took once
+	// every user is done.
+	started time.Time
+	elapsed time.Duration
+
+	// results lists the finished scan below the pinned username.
+	results table.Model
+	sortBy  int
+	// showDomains swaps the table for the count of emails by domain.
+	showDomains bool
+	// showMatrix swaps the table for the commits per repo of each email.
+	showMatrix    bool
+	status        string
+	width, height int
 }
 
-type dataMsg struct{ data []string }
-type errMsg struct{ err error }
+// userResult is the outcome of the scan of one user.
+type userResult struct {
+	user      string
+	renamedTo string
+	data      []sniffer.Contact
+	failed    []sniffer.RepoError
+	stats     sniffer.Stats
+	partial   bool
+	// sampledFrom is the number of repos listed when only -sample of
+	// them were scanned.
+	sampledFrom int
+	// cutShort lists the repos -max-commits stopped reading early.
+	cutShort []string
+	err      error
+}
+
+type dataMsg struct {
+	user      string
+	renamedTo string
+	data      []sniffer.Contact
+	failed    []sniffer.RepoError
+	stats     sniffer.Stats
+	// partial is set when the scan was cancelled before every repo was
+	// read.
+	partial bool
+	// sampledFrom is the number of repos listed when only -sample of
+	// them were scanned, 0 otherwise.
+	sampledFrom int
+	cutShort    []string
+	budget      sniffer.Budget
+}
+type errMsg struct {
+	user string
+	err  error
+}
 
 func (e errMsg) Error() string { return e.err.Error() }
+func (e errMsg) Unwrap() error { return e.err }
 
-var auth string
-var debug bool
+var verbose, quiet bool
+var logFile string
+var format string
+var extraPlaceholders string
+var sinceLastRun bool
+var self bool
+var users listFlag
+var inputFile string
+var repo string
+var output string
+var useCache bool
+var noCache bool
+var cacheMaxAge time.Duration
+var proxy string
+var listRepos bool
+var pickRepos bool
+var noProxy bool
+var serve string
+var themeName string
+var noColor bool
+var rps float64
+var confirmThreshold int
 
-func getRepos(user string) (error, []string) {
-	_, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// opts carries the scan settings given on the command line.
+var opts sniffer.Options
 
-	data := []string{}
-	c := &http.Client{Timeout: 10 * time.Second}
+// listFlag collects the values of a flag that may be repeated.
+type listFlag []string
 
-	url := fmt.Sprintf("%s/%s/repos", baseUsers, user)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return err, data
-	}
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
 
-	if auth != "" {
-		req.Header.Set(
-			"Authorization",
-			fmt.Sprintf("Bearer %s", auth),
-		)
-	}
-	res, err := c.Do(req)
-	if err != nil {
-		return err, data
-	}
-	defer res.Body.Close()
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return err, data
-	}
+func (l *listFlag) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
 
-	var repoData []RepoDataPiece
-	err = json.Unmarshal(body, &repoData)
-	if err != nil {
-		log.Fatal(err, string(body))
-		return err, data
-	}
+// splitUsers splits the nickname input on commas and whitespace.
+func splitUsers(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+}
 
-	for _, d := range repoData {
-		repo := d.FullName
-		data = append(data, repo)
+// readUsers reads one nickname per line, skipping blank lines and # comments.
+func readUsers(r io.Reader) ([]string, error) {
+	var users []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		users = append(users, line)
 	}
+	return users, sc.Err()
+}
 
-	return nil, data
+// stdinPiped tells whether stdin is a pipe or file rather than a terminal.
+func stdinPiped() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice == 0
 }
 
-func getRepoEmails(fullName string) (error, []string) {
-	_, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// stdoutPiped tells whether stdout goes to a file or pipe rather than a
+// terminal.
+func stdoutPiped() bool {
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice == 0
+}
 
-	data := []string{}
-	c := &http.Client{Timeout: 10 * time.Second}
-	url := fmt.Sprintf("%s/%s/commits", baseRepos, fullName)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return err, data
-	}
+// newClient returns a client keeping enough idle connections around for
+// every worker. It is shared by all scans so connections to the API are
+// reused. No single request may take longer than timeout; the request
+// contexts add a deadline for whole listings.
+func newClient(concurrency int, timeout time.Duration, proxy func(*http.Request) (*url.URL, error)) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = proxy
+	t.MaxIdleConns = 100
+	t.MaxIdleConnsPerHost = max(concurrency, 2)
+	t.IdleConnTimeout = 90 * time.Second
+	return &http.Client{Transport: t, Timeout: timeout}
+}
 
-	if auth != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", auth))
-	}
-	res, err := c.Do(req)
-	if err != nil {
-		return err, data
-	}
-	defer res.Body.Close()
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return err, data
+// proxyFunc picks the proxy of every request: the -proxy URL when given,
+// none with -no-proxy, otherwise the one named by HTTP_PROXY, HTTPS_PROXY
+// and NO_PROXY.
+func proxyFunc(raw string, noProxy bool) (func(*http.Request) (*url.URL, error), error) {
+	switch {
+	case raw != "":
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("proxy %q: want an http, https or socks5 URL", raw)
+		}
+		return http.ProxyURL(u), nil
+	case noProxy:
+		return nil, nil
 	}
+	return http.ProxyFromEnvironment, nil
+}
 
-	var commitData []CommitDataPiece
-	err = json.Unmarshal(body, &commitData)
-	if err != nil {
-		return err, data
-	}
+type progressMsg struct {
+	user        string
+	done, total int
+	budget      sniffer.Budget
+}
 
-	uniqueEmails := make(map[string]struct{})
-	for _, d := range commitData {
-		email := d.Commit.Author.Email
-		_, exists := uniqueEmails[email]
-		if !exists {
-			data = append(data, email)
-			uniqueEmails[email] = struct{}{}
-		}
-	}
+// repoStartMsg tells that the reading of a repo started.
+type repoStartMsg struct{ repo string }
 
-	return nil, data
+// repoMsg carries the outcome of one repo of a running scan, so its
+// contacts show before the scan is done.
+type repoMsg struct {
+	repo     string
+	contacts []sniffer.Contact
+	err      error
 }
 
-type Wrapper struct {
-	data []string
+// startMsg asks for an immediate scan of users, as with -self.
+type startMsg struct{ users []string }
+
+// checkServer scans every user in the background until ctx is cancelled,
+// only the repos picked for them when picked is not nil. Progress, the
+// start and outcome of each repo and then one final dataMsg or errMsg
+// per user arrive on events; the returned command delivers the first of them,
+// waitForScan the rest.
+func checkServer(ctx context.Context, users []string, picked map[string][]string, events chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		for _, user := range users {
+			go func() {
+				// Bubble Tea only catches panics in its own goroutines. One
+				// here would leave the terminal in raw mode, so it fails
+				// the scan of user instead.
+				defer func() {
+					if r := recover(); r != nil {
+						opts.Logger.Error("scan panicked", "user", user, "panic", r, "stack", string(debug.Stack()))
+						events <- errMsg{user: user, err: fmt.Errorf("internal error: %v", r)}
+					}
+				}()
+				events <- scan(ctx, user, picked[user], func(done, total int, budget sniffer.Budget) {
+					events <- progressMsg{user, done, total, budget}
+				}, func(repo string) {
+					events <- repoStartMsg{repo}
+				}, func(r sniffer.RepoError) {
+					logRepo(r)
+					events <- repoMsg{r.Repo, r.Contacts, r.Err}
+				})
+			}()
+		}
+		return <-events
+	}
 }
 
-func checkServer(user string) tea.Cmd {
+func waitForScan(events chan tea.Msg) tea.Cmd {
 	return func() tea.Msg {
-		var wg sync.WaitGroup
+		return <-events
+	}
+}
+
+// scan runs sniffer.Sniff on user with the command line options, on
+// repos only if not empty. progress, with the rate limit budget left, and
+// repoDone, if not nil, are called each time a repo is done, repoStart as
+// one starts.
+func scan(ctx context.Context, user string, repos []string, progress func(done, total int, budget sniffer.Budget), repoStart func(string), repoDone func(sniffer.RepoError)) tea.Msg {
+	start := time.Now()
 
-		err, repos := getRepos(user)
+	o := opts
+	if repos != nil {
+		o.Repos = repos
+	}
+	// Each scan reports the budget its own responses told of.
+	var budget struct {
+		sync.Mutex
+		sniffer.Budget
+	}
+	o.BudgetChanged = func(b sniffer.Budget) {
+		budget.Lock()
+		defer budget.Unlock()
+		budget.Budget = b
+	}
+	if progress != nil {
+		o.Progress = func(done, total int) {
+			budget.Lock()
+			b := budget.Budget
+			budget.Unlock()
+			progress(done, total, b)
+		}
+	}
+	o.RepoStart = repoStart
+	o.RepoDone = repoDone
+	if sinceLastRun {
+		lastRun, err := loadLastRun(user)
 		if err != nil {
-			return errMsg{err}
+			return errMsg{user, err}
+		}
+		if lastRun.After(o.Since) {
+			o.Since = lastRun
 		}
+	}
 
-		//repos = repos[:1]
+	r, err := sniffer.Sniff(ctx, user, o)
+	if err != nil {
+		return errMsg{user, err}
+	}
 
-		repoEmailsChan := make(chan Wrapper, len(repos))
-		if debug {
-			fmt.Println()
+	// Failed, unread, unsampled and cut short repos must be retried by the
+	// next incremental run.
+	if sinceLastRun && len(r.Failed) == 0 && !r.Partial && r.SampledFrom == 0 && len(r.CutShort) == 0 {
+		if err := saveLastRun(user, start); err != nil {
+			return errMsg{user, err}
 		}
-		for _, repo := range repos {
-			wg.Add(1)
-			go func(repo string, c chan Wrapper) {
-				defer wg.Done()
-				err, repoEmails := getRepoEmails(repo)
-				if err != nil {
-					log.Fatal(err)
-				}
-				if debug {
-					fmt.Printf("%s: %v\n", repo, repoEmails)
-				}
-				c <- Wrapper{data: repoEmails}
-			}(repo, repoEmailsChan)
-		}
-		wg.Wait()
-		close(repoEmailsChan)
-
-		data := []string{}
-		uniqueEmails := make(map[string]struct{})
-		for repoEmails := range repoEmailsChan {
-			for _, email := range repoEmails.data {
-				_, exists := uniqueEmails[email]
-				if !exists {
-					data = append(data, email)
-					uniqueEmails[email] = struct{}{}
-				}
-			}
-		}
-		return dataMsg{data}
 	}
+	return dataMsg{user: user, renamedTo: r.RenamedTo, data: r.Contacts, failed: r.Failed, stats: r.Stats, partial: r.Partial, sampledFrom: r.SampledFrom, cutShort: r.CutShort, budget: r.Budget}
 }
 
-func initialModel() model {
+func initialModel(login string) model {
 	m := model{
-		inputs: make([]textinput.Model, 1),
+		inputs:   make([]textinput.Model, 1),
+		login:    login,
+		events:   make(chan tea.Msg),
+		spinner:  spinner.New(spinner.WithSpinner(spinner.Dot), spinner.WithStyle(focusedStyle)),
+		help:     newHelp(),
+		progress: progress.New(progress.WithDefaultGradient()),
 	}
 
 	var t textinput.Model
 	for i := range m.inputs {
 		t = textinput.New()
 		t.Cursor.Style = cursorStyle
-		t.CharLimit = 32
+		// Room for a few comma-separated nicknames.
+		t.CharLimit = 256
 
 		switch i {
 		case 0:
@@ -232,30 +421,332 @@ func initialModel() model {
 		m.inputs[i] = t
 	}
 
+	if login != "" {
+		m.inputs[0].SetValue(login)
+		m.isLoading = true
+	}
+
 	return m
 }
 
+// startScan switches to the loading screen and kicks off a scan of users,
+// of the repos picked for them if picked is not nil.
+func (m *model) startScan(users []string, picked map[string][]string) tea.Cmd {
+	if m.cancel != nil {
+		// Whatever ran before, a repo listing say, is over.
+		m.cancel()
+	}
+	var ctx context.Context
+	ctx, m.cancel = context.WithCancel(context.Background())
+	m.isLoading, m.cancelling = true, false
+	m.started = time.Now()
+	m.scans = make([]userResult, len(users))
+	for i, user := range users {
+		m.scans[i].user = user
+	}
+	m.pending = len(users)
+	m.done = make(map[string]int)
+	m.total = make(map[string]int)
+	m.live, m.liveIndex = nil, make(map[string]int)
+	m.repos, m.repoIndex = nil, make(map[string]int)
+	return tea.Batch(m.spinner.Tick, checkServer(ctx, users, picked, m.events))
+}
+
+// searchAgain goes back to the nickname input for a new scan, keeping
+// what was typed and the cursor mode.
+func (m *model) searchAgain() tea.Cmd {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.isLoading, m.isFinished = false, false
+	m.listing, m.picking, m.confirming, m.browsing = false, false, false, false
+	m.data, m.failed, m.err = nil, nil, nil
+	m.scans, m.current = nil, 0
+	m.status = ""
+	// The -self login was only for the first scan.
+	m.login = ""
+
+	m.focusIndex = 0
+	m.inputs[0].PromptStyle = focusedStyle
+	m.inputs[0].TextStyle = focusedStyle
+	m.inputs[0].CursorEnd()
+	return m.inputs[0].Focus()
+}
+
+// finishScan records the final message of the scan of user and tells
+// whether every scan is done.
+func (m *model) finishScan(user string, r userResult) bool {
+	for i := range m.scans {
+		if m.scans[i].user == user {
+			m.scans[i] = r
+		}
+	}
+	m.pending--
+	if m.pending > 0 {
+		return false
+	}
+	m.elapsed = time.Since(m.started)
+	return true
+}
+
+// showResults switches to the results screen once every scan is done
+// and records the search in the history.
+func (m *model) showResults() {
+	m.isFinished = true
+	m.results = newResultsTable()
+	m.showUser(0)
+	addHistory(m.sniffResults())
+}
+
+// sniffResults returns the results of the users whose scan succeeded.
+func (m model) sniffResults() []sniffer.Result {
+	var results []sniffer.Result
+	for _, r := range m.scans {
+		if r.err == nil {
+			results = append(results, sniffer.Result{User: r.user, RenamedTo: r.renamedTo, Contacts: r.data, Failed: r.failed, Stats: r.stats, Partial: r.partial, SampledFrom: r.sampledFrom, CutShort: r.cutShort})
+		}
+	}
+	return results
+}
+
+// showUser switches the results screen to the i-th scanned user.
+func (m *model) showUser(i int) {
+	m.current = (i + len(m.scans)) % len(m.scans)
+	r := m.scans[m.current]
+	m.data, m.failed = r.data, r.failed
+	m.status = ""
+	m.layoutResults()
+}
+
+// progressTotals sums the progress of all running scans.
+func (m model) progressTotals() (done, total int) {
+	for _, r := range m.scans {
+		done += m.done[r.user]
+		total += m.total[r.user]
+	}
+	return done, total
+}
+
 func (m model) Init() tea.Cmd {
-	return textinput.Blink
+	cmds := []tea.Cmd{textinput.Blink, fetchTier}
+	if m.login != "" {
+		cmds = append(cmds, func() tea.Msg { return startMsg{[]string{m.login}} })
+	}
+	return tea.Batch(cmds...)
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 
+	case startMsg:
+		cmd := m.beginScan(msg.users)
+		return m, cmd
+
+	case tierMsg:
+		m.tier = string(msg)
+		return m, nil
+
+	case reposMsg:
+		if pickRepos {
+			m.showPicker(msg)
+			return m, nil
+		}
+		cmd := m.confirmScan(msg)
+		return m, cmd
+
+	case spinner.TickMsg:
+		if !m.isLoading || m.isFinished {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+
+	case progressMsg:
+		m.done[msg.user], m.total[msg.user] = msg.done, msg.total
+		if msg.budget.Limit > 0 {
+			// Cached responses don't tell.
+			m.budget = msg.budget
+		}
+		return m, waitForScan(m.events)
+
+	case repoStartMsg:
+		m.startRepo(msg.repo)
+		return m, waitForScan(m.events)
+
+	case repoMsg:
+		m.finishRepo(msg.repo, len(msg.contacts), msg.err)
+		m.addLive(msg.contacts)
+		return m, waitForScan(m.events)
+
 	case dataMsg:
-		m.data = msg.data
-		m.isFinished = true
-		return m, tea.Quit
+		if msg.budget.Limit > 0 {
+			m.budget = msg.budget
+		}
+		if !m.finishScan(msg.user, userResult{user: msg.user, renamedTo: msg.renamedTo, data: msg.data, failed: msg.failed, stats: msg.stats, partial: msg.partial, sampledFrom: msg.sampledFrom, cutShort: msg.cutShort}) {
+			return m, waitForScan(m.events)
+		}
+		m.showResults()
+		return m, nil
 	case errMsg:
-		m.err = msg
-		m.isFinished = true
-		return m, tea.Quit
+		if m.cancelling && (m.listing || len(m.scans) == 1) && errors.Is(msg.err, context.Canceled) {
+			// Stopped while listing or before a single repo was read,
+			// nothing to show.
+			cmd := m.searchAgain()
+			return m, cmd
+		}
+		if m.listing || len(m.scans) == 1 {
+			m.err = msg
+			m.isFinished = true
+			return m, tea.Quit
+		}
+		// With several users one failure doesn't hide the others.
+		if !m.finishScan(msg.user, userResult{user: msg.user, err: msg.err}) {
+			return m, waitForScan(m.events)
+		}
+		m.showResults()
+		return m, nil
+
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.help.Width = m.width
+		m.progress.Width = min(m.width, 60)
+		if m.isFinished {
+			m.layoutResults()
+		}
+		if m.picking {
+			m.picker.SetSize(m.width, m.height)
+		}
+		if m.browsing {
+			m.history.SetSize(m.width, m.height)
+		}
 
 	case tea.KeyMsg:
+		if m.showHelp {
+			switch msg.String() {
+			case "ctrl+c":
+				return m, tea.Quit
+			case "?", "esc", "q":
+				m.showHelp = false
+			}
+			return m, nil
+		}
+		if msg.String() == "?" && (m.isFinished || !(m.isLoading || m.picking || m.confirming || m.browsing)) {
+			m.showHelp = true
+			return m, nil
+		}
+
+		if m.isFinished {
+			switch msg.String() {
+			case "ctrl+c", "esc", "q":
+				return m, tea.Quit
+			case "c":
+				m.status = "copied!"
+				if err := clipboard.WriteAll(m.emails()); err != nil {
+					m.status = fmt.Sprintf("copy failed: %v", err)
+				}
+				return m, nil
+			case "enter":
+				// Just the highlighted address.
+				data := m.sorted()
+				if len(data) == 0 {
+					return m, nil
+				}
+				email := data[m.results.Cursor()].Email
+				m.status = "copied " + email
+				if err := clipboard.WriteAll(email); err != nil {
+					m.status = fmt.Sprintf("copy failed: %v", err)
+				}
+				return m, nil
+			case "s":
+				m.sortBy = (m.sortBy + 1) % sortColumns
+				m.layoutResults()
+				return m, nil
+			case "d":
+				m.showDomains = !m.showDomains
+				m.showMatrix = false
+				return m, nil
+			case "m":
+				m.showMatrix = !m.showMatrix
+				m.showDomains = false
+				return m, nil
+			case "r", "/":
+				cmd := m.searchAgain()
+				return m, cmd
+			case "h":
+				m.openHistory()
+				return m, nil
+			case "tab":
+				m.showUser(m.current + 1)
+				return m, nil
+			case "shift+tab":
+				m.showUser(m.current - 1)
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.results, cmd = m.results.Update(msg)
+			return m, cmd
+		}
+
+		if m.picking {
+			if msg.String() == "ctrl+c" {
+				return m, tea.Quit
+			}
+			cmd := m.updatePicker(msg)
+			return m, cmd
+		}
+
+		if m.browsing {
+			if msg.String() == "ctrl+c" {
+				return m, tea.Quit
+			}
+			cmd := m.updateHistory(msg)
+			return m, cmd
+		}
+
+		if m.confirming {
+			switch msg.String() {
+			case "ctrl+c":
+				return m, tea.Quit
+			case "y", "Y":
+				m.confirming = false
+				cmd := m.startScan(m.pickUsers, m.listed)
+				return m, cmd
+			}
+			// Anything else is a no.
+			cmd := m.searchAgain()
+			return m, cmd
+		}
+
+		if m.isLoading {
+			switch msg.String() {
+			case "esc":
+				if !m.cancelling && m.cancel != nil {
+					// Stop the scans; what they read so far arrives as
+					// partial results.
+					m.cancelling = true
+					m.cancel()
+					return m, nil
+				}
+				return m, tea.Quit
+			case "ctrl+c":
+				if m.cancel != nil {
+					// Abort the requests still in flight.
+					m.cancel()
+				}
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+
 		switch msg.String() {
 		case "ctrl+c", "esc":
 			return m, tea.Quit
 
+		case "ctrl+p":
+			m.openHistory()
+			return m, nil
+
 		// Change cursor mode
 		case "ctrl+r":
 			m.cursorMode++
@@ -275,8 +766,20 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Did the user press enter while the submit button was focused?
 			// If so, exit.
 			if s == "enter" && m.focusIndex == len(m.inputs) {
-				m.isLoading = true
-				return m, checkServer(m.inputs[0].Value())
+				users := splitUsers(m.inputs[0].Value())
+				if len(users) == 0 {
+					return m, nil
+				}
+				// Catch typos before they cost a scan.
+				for i := range users {
+					var err error
+					if users[i], err = opts.ParseUser(users[i]); err != nil {
+						m.inputErr = err.Error()
+						return m, nil
+					}
+				}
+				cmd := m.beginScan(users)
+				return m, cmd
 			}
 
 			// Cycle indexes
@@ -312,6 +815,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	// Handle character input and blinking
+	if _, ok := msg.(tea.KeyMsg); ok {
+		m.inputErr = ""
+	}
 	cmd := m.updateInputs(msg)
 
 	return m, cmd
@@ -329,21 +835,90 @@ func (m *model) updateInputs(msg tea.Msg) tea.Cmd {
 	return tea.Batch(cmds...)
 }
 
+// describeError explains err and what to do about it.
+func describeError(err error) string {
+	var (
+		rateLimit sniffer.RateLimitError
+		notFound  sniffer.UserNotFoundError
+		auth      sniffer.AuthError
+		network   sniffer.NetworkError
+		decode    sniffer.DecodeError
+	)
+	switch {
+	case errors.As(err, &rateLimit) && rateLimit.Secondary:
+		return fmt.Sprintf(
+			"GitHub asked to slow down, retry after %s. Lower -concurrency or set -rps.",
+			rateLimit.ResetAt.Local().Format("15:04:05"),
+		)
+	case errors.As(err, &rateLimit):
+		return fmt.Sprintf(
+			"Rate limit reached, resets at %s. Passing -auth raises the limit.",
+			rateLimit.ResetAt.Local().Format("15:04"),
+		)
+	case errors.As(err, &notFound):
+		return fmt.Sprintf("There is no user or organization named %s. Check the spelling.", notFound.User)
+	case errors.As(err, &auth) && auth.Code == http.StatusUnauthorized:
+		return "The token was rejected. Check that it is valid and hasn't expired."
+	case errors.As(err, &auth):
+		return fmt.Sprintf("Access denied to %s. The token may lack a scope, or need SSO authorization.", auth.Path)
+	case errors.As(err, &network) && errors.Is(err, context.DeadlineExceeded):
+		return "The API took too long to answer. Try again, or raise -timeout."
+	case errors.As(err, &network):
+		return fmt.Sprintf("Could not reach the API: %v. Check your connection or -proxy.", network.Err)
+	case errors.Is(err, context.Canceled):
+		return "Cancelled before any repo was read."
+	case errors.As(err, &decode):
+		return fmt.Sprintf("The API sent something other than JSON for %s, maybe an outage page. Try again later.\n\n%v", decode.Path, decode)
+	}
+	return fmt.Sprintf("We had some trouble: %v", err)
+}
+
 func (m model) View() string {
 	if m.err != nil {
-		return fmt.Sprintf("\nWe had some trouble: %v\n\n", m.err)
+		return "\n" + describeError(m.err) + "\n\n"
+	}
+	if m.showHelp {
+		return m.helpView()
 	}
 	if m.isFinished {
-		s := m.inputs[0].Value() + "\n"
-		for i, email := range m.data {
-			s += fmt.Sprintf("%d.\t%s\n", i+1, email)
-		}
-
-		return s + "\n\n"
+		return m.resultsView()
+	}
+	if m.picking {
+		return m.picker.View()
+	}
+	if m.browsing {
+		return m.history.View()
+	}
+	if m.confirming {
+		return fmt.Sprintf(
+			"This will scan %d repos (~%d API calls). Continue? [y/N]",
+			m.confirmRepos, estimateCalls(m.confirmRepos),
+		)
 	}
 
 	if m.isLoading {
-		return "Loading..."
+		var s string
+		if m.login != "" {
+			s = fmt.Sprintf("Authenticated as %s\n", m.login)
+		}
+		done, total := m.progressTotals()
+		if total == 0 {
+			return s + m.spinner.View() + " Loading..."
+		}
+		percent := float64(done) / float64(total)
+		s = fmt.Sprintf(
+			"%s%s\n%s %d/%d repos scanned",
+			s, m.progress.ViewAs(percent), m.spinner.View(), done, total,
+		)
+		if m.budget.Limit > 0 {
+			s += "\n" + helpStyle.Render(m.budget.String())
+		}
+		if m.cancelling {
+			s += "\n" + helpStyle.Render("cancelling, esc again to quit")
+		} else {
+			s += "\n" + helpStyle.Render("esc to stop and see what was found • ctrl+c to quit")
+		}
+		return s + m.reposView() + m.liveView()
 	}
 
 	var b strings.Builder
@@ -355,6 +930,13 @@ func (m model) View() string {
 		}
 	}
 
+	if m.inputErr != "" {
+		b.WriteString("\n" + errorStyle.Render(m.inputErr))
+	}
+	if m.tier != "" {
+		b.WriteString("\n" + m.tier)
+	}
+
 	button := &blurredButton
 	if m.focusIndex == len(m.inputs) {
 		button = &focusedButton
@@ -363,16 +945,345 @@ func (m model) View() string {
 
 	b.WriteString(helpStyle.Render("cursor mode is "))
 	b.WriteString(cursorModeHelpStyle.Render(m.cursorMode.String()))
-	b.WriteString(helpStyle.Render(" (ctrl+r to change style)"))
+	b.WriteString(helpStyle.Render(" (ctrl+r to change style, ctrl+p for past searches, ? for help)"))
 
 	return b.String()
 }
 
+// regexpFlag returns a flag.Func setter compiling the pattern into re.
+func regexpFlag(re **regexp.Regexp) func(string) error {
+	return func(s string) error {
+		var err error
+		*re, err = regexp.Compile(s)
+		return err
+	}
+}
+
+// globsFlag returns a flag.Func setter adding comma-separated glob
+// patterns to globs. The flag may be repeated.
+func globsFlag(globs *[]string) func(string) error {
+	return func(s string) error {
+		for _, g := range strings.Split(s, ",") {
+			if g = strings.TrimSpace(g); g == "" {
+				continue
+			}
+			if _, err := path.Match(g, ""); err != nil {
+				return fmt.Errorf("bad pattern %q", g)
+			}
+			*globs = append(*globs, g)
+		}
+		return nil
+	}
+}
+
+// parseDateFlag returns a flag.Func setter accepting RFC3339 timestamps
+// and plain YYYY-MM-DD dates.
+func parseDateFlag(t *time.Time) func(string) error {
+	return func(s string) error {
+		var err error
+		if *t, err = time.Parse(time.RFC3339, s); err == nil {
+			return nil
+		}
+		if *t, err = time.Parse(time.DateOnly, s); err == nil {
+			return nil
+		}
+		return fmt.Errorf("want RFC3339 or YYYY-MM-DD, got %q", s)
+	}
+}
+
 func main() {
-	flag.BoolVar(&debug, "debug", false, "Print every repo result")
-	flag.StringVar(&auth, "auth", "", "GitHub Bearer token")
+	flag.BoolVar(&verbose, "verbose", false, "Log every repo and retried request")
+	flag.BoolVar(&verbose, "debug", false, "Same as -verbose")
+	flag.BoolVar(&quiet, "quiet", false, "Only log errors, and don't list the repos that failed")
+	flag.StringVar(&logFile, "log-file", "", "Append logs to this file instead of stderr")
+	flag.StringVar(&opts.Auth, "auth", "", "API token, defaults to $GH_TOKEN or $GITHUB_TOKEN, $GITLAB_TOKEN or $BITBUCKET_TOKEN with their -provider; user:app-password on Bitbucket")
+	flag.StringVar(&format, "format", "", "Write results to stdout as text, plain, json, jsonl, csv, html, markdown, matrix or vcf; plain when piped")
+	flag.StringVar(&extraPlaceholders, "placeholders", "", "Comma-separated extra placeholder email patterns")
+	flag.BoolVar(&sinceLastRun, "since-last-run", false, "Only scan commits made since the previous run for this user")
+	flag.BoolVar(&self, "self", false, "Scan the account the token belongs to")
+	flag.BoolVar(&opts.IncludePrivate, "include-private", false, "Include your private repos when scanning the token's own account")
+	flag.StringVar(&opts.DedupKey, "dedup-key", "email", "How identities collapse: "+sniffer.DedupKeyNames())
+	flag.IntVar(&opts.MaxRepos, "max-repos", 0, "Stop listing repos after this many, 0 for no limit")
+	flag.IntVar(&opts.Sample, "sample", 0, "Scan only this many repos of each user, picked at random, for a quick estimate")
+	flag.Uint64Var(&opts.SampleSeed, "sample-seed", 0, "Seed of the -sample pick, to get the same repos again; 0 for a new pick every run")
+	flag.IntVar(&opts.MaxCommits, "max-commits", 0, "Read at most this many commits per repo, 0 for no limit (100 without a token)")
+	flag.BoolVar(&opts.FirstParent, "first-parent", false, "Only read the first-parent line of the default branch, leaving out merged branches")
+	flag.IntVar(&opts.MinCommits, "min-commits", 0, "Drop emails with fewer commits than this over all repos")
+	flag.StringVar(&opts.UserAgent, "user-agent", "github-sniffer/"+version, "User-Agent sent to GitHub")
+	flag.IntVar(&opts.Concurrency, "concurrency", 8, "Number of repos scanned at the same time (2 without a token)")
+	flag.Float64Var(&rps, "rps", 0, "Send at most this many requests per second, e.g. 2 or 0.5; 0 for no limit")
+	flag.Var(&users, "user", "Scan this user without the interactive UI, may be repeated")
+	flag.StringVar(&repo, "repo", "", "Scan only this repo, as owner/name or its URL, without the interactive UI")
+	flag.StringVar(&output, "output", "", "Write results to this file instead of stdout")
+	flag.BoolVar(&opts.AuthorsOnly, "authors-only", false, "Ignore committer identities, only collect authors")
+	flag.BoolVar(&opts.IncludeNoreply, "include-noreply", false, "Keep users.noreply.github.com addresses")
+	flag.BoolVar(&opts.NoBots, "no-bots", false, "Drop bot identities like dependabot[bot] and actions@github.com")
+	flag.Func("filter", "Only keep emails matching this Go regexp, e.g. '@example\\.com$'", regexpFlag(&opts.Filter))
+	flag.Func("exclude", "Drop emails matching this Go regexp", regexpFlag(&opts.Exclude))
+	flag.BoolVar(&opts.CaseSensitive, "case-sensitive", false, "Keep addresses as committed instead of trimming and lowercasing them")
+	flag.BoolVar(&opts.ResolveNoreply, "resolve-noreply", false, "Look up the current login behind numeric noreply addresses")
+	flag.BoolVar(&opts.ResolveLogins, "resolve-logins", false, "Search the account of every address, slow: searches have their own low rate limit")
+	flag.StringVar(&opts.Provider, "provider", "github", "Where to scan: github, gitlab or bitbucket")
+	flag.StringVar(&opts.BaseURL, "base-url", "", "API root, e.g. https://github.example.com/api/v3 for Enterprise; api.github.com, gitlab.com or api.bitbucket.org by default")
+	flag.BoolVar(&opts.Org, "org", false, "Treat the nickname as an organization")
+	flag.BoolVar(&opts.NoForks, "no-forks", false, "Skip forked repos")
+	flag.Func("exclude-repos", "Skip repos matching these comma-separated globs, e.g. '*-archive,dotfiles'", globsFlag(&opts.ExcludeRepos))
+	flag.DurationVar(&opts.Timeout, "timeout", 10*time.Second, "Deadline of every API request and repo listing, e.g. 30s on slow networks")
+	flag.IntVar(&opts.MaxRetries, "max-retries", 3, "Retries for rate limited (Retry-After) and 5xx responses, and dropped connections")
+	flag.StringVar(&opts.Source, "source", "commits", "Where emails come from: commits or contributors")
+	flag.BoolVar(&opts.GraphQL, "graphql", false, "Gather commits through the GraphQL API in far fewer requests, needs a token")
+	flag.Func("since", "Only scan commits after this date (RFC3339 or YYYY-MM-DD)", parseDateFlag(&opts.Since))
+	flag.Func("until", "Only scan commits before this date (RFC3339 or YYYY-MM-DD)", parseDateFlag(&opts.Until))
+	flag.BoolVar(&opts.IncludeEvents, "include-events", false, "Also collect commit authors from the user's public push events")
+	flag.BoolVar(&opts.IncludeGists, "include-gists", false, "Also collect commit identities from the user's public gists, cloned with git")
+	flag.DurationVar(&opts.CacheTTL, "cache-ttl", 0, "With -cache, reuse cached API responses younger than this without revalidating, e.g. 1h")
+	flag.DurationVar(&opts.ReposCacheTTL, "repos-cache-ttl", 0, "Like -cache-ttl for the repo listings of users, e.g. 1h; 0 for the same as -cache-ttl")
+	flag.BoolVar(&useCache, "cache", false, "Keep API responses on disk and revalidate them instead of downloading them again")
+	flag.BoolVar(&noCache, "no-cache", false, "Neither read nor write the response cache, even with -cache")
+	flag.DurationVar(&cacheMaxAge, "cache-max-age", 7*24*time.Hour, "Delete cached responses not used for this long, 0 to keep them")
+	flag.StringVar(&proxy, "proxy", "", "Send requests through this proxy, e.g. socks5://localhost:1080 or http://proxy:3128")
+	flag.BoolVar(&noProxy, "no-proxy", false, "Ignore HTTP_PROXY and HTTPS_PROXY")
+	flag.IntVar(&confirmThreshold, "confirm-threshold", 100, "Ask before scanning more repos than this in the interactive UI, 0 to never ask")
+	flag.BoolVar(&pickRepos, "pick-repos", false, "Pick the repos to scan from a list first, in the interactive UI")
+	flag.BoolVar(&listRepos, "list-repos", false, "Only print the repos that would be scanned, without reading commits")
+	flag.StringVar(&inputFile, "input-file", "", "Read nicknames to scan from this file, one per line")
+	flag.BoolVar(&noColor, "no-color", false, "Draw the interactive UI without colors or styles, as NO_COLOR does")
+	flag.StringVar(&themeName, "theme", "dark", "Colors of the interactive UI: "+themeNames())
+	flag.StringVar(&serve, "serve", "", "Serve scans over HTTP on this address, e.g. :8080, as GET /scan?user=NAME")
 	flag.Parse()
-	if _, err := tea.NewProgram(initialModel()).Run(); err != nil {
-		log.Printf("could not start program: %s\n", err)
+	users = append(users, flag.Args()...)
+
+	if repo != "" {
+		if len(users) > 0 || inputFile != "" || self {
+			log.Fatal("-repo scans one repo, without -user, -input-file or -self")
+		}
+		if sinceLastRun || listRepos || serve != "" {
+			log.Fatal("-repo can't be combined with -since-last-run, -list-repos or -serve")
+		}
+		owner, fullName, err := opts.ParseRepo(repo)
+		if err != nil {
+			log.Fatal(err)
+		}
+		users = listFlag{owner}
+		opts.Repos = []string{fullName}
+	}
+
+	if inputFile != "" {
+		f, err := os.Open(inputFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		more, err := readUsers(f)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		users = append(users, more...)
+	} else if len(users) == 0 && stdinPiped() {
+		more, err := readUsers(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		users = append(users, more...)
+	}
+
+	if opts.Source == "contributors" && (sinceLastRun || !opts.Since.IsZero() || !opts.Until.IsZero()) {
+		log.Fatal("-since, -until and -since-last-run need -source commits")
+	}
+
+	// Same lookup order as the gh CLI. A GitHub token has no business
+	// going to another forge.
+	envs := []string{"GH_TOKEN", "GITHUB_TOKEN"}
+	switch opts.Provider {
+	case "gitlab":
+		envs = []string{"GITLAB_TOKEN"}
+	case "bitbucket":
+		envs = []string{"BITBUCKET_TOKEN"}
+	}
+	for _, env := range envs {
+		if opts.Auth == "" {
+			opts.Auth = os.Getenv(env)
+		}
+	}
+
+	if !useCache && (opts.CacheTTL > 0 || opts.ReposCacheTTL > 0) {
+		log.Fatal("-cache-ttl and -repos-cache-ttl need -cache")
+	}
+	if useCache && !noCache {
+		// Without a cache directory the scan just goes uncached.
+		opts.CacheDir, _ = sniffer.DefaultCacheDir()
+	}
+	if opts.IncludePrivate && opts.Auth == "" {
+		log.Fatal("-include-private needs a token to see private repos, pass -auth or set GH_TOKEN")
+	}
+	anonymous := opts.Provider == "github" && opts.Auth == "" && serve == ""
+	if anonymous {
+		// 60 requests an hour go in seconds at full speed; scan gently
+		// unless told otherwise.
+		set := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		if !set["concurrency"] {
+			opts.Concurrency = anonymousConcurrency
+		}
+		if !set["max-commits"] {
+			opts.MaxCommits = anonymousMaxCommits
+		}
+	}
+	for _, p := range strings.Split(extraPlaceholders, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			opts.Placeholders = append(opts.Placeholders, p)
+		}
+	}
+	if err := opts.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	proxyURL, err := proxyFunc(proxy, noProxy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts.Client = newClient(opts.Concurrency, opts.Timeout, proxyURL)
+	// One limit for all the users scanned side by side.
+	opts.Limiter = sniffer.NewLimiter(opts.Concurrency)
+	switch {
+	case rps < 0:
+		log.Fatal("-rps can't be negative")
+	case rps > 0:
+		// A burst of one spaces requests evenly.
+		opts.Throttle = rate.NewLimiter(rate.Limit(rps), 1)
+	}
+
+	emitter, ok := emitters[format]
+	if format != "" && !ok {
+		log.Fatalf("unknown format %q", format)
+	}
+	// Piped output gets bare addresses, not the screens of the UI.
+	if emitter == nil {
+		switch {
+		case output == "" && stdoutPiped():
+			emitter = plainEmitter{}
+		case len(users) > 0 || output != "":
+			emitter = textEmitter{}
+		}
+	}
+
+	var programOpts []tea.ProgramOption
+	uiOutput := os.Stdout
+	if emitter != nil && output == "" {
+		// Keep stdout clean for the report, and pick colors for the
+		// terminal the UI does draw on.
+		uiOutput = os.Stderr
+		programOpts = append(programOpts, tea.WithOutput(os.Stderr))
+		lipgloss.SetDefaultRenderer(lipgloss.NewRenderer(os.Stderr))
+	}
+	if noColor {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+	if err := applyTheme(themeName); err != nil {
+		log.Fatal(err)
+	}
+
+	for i := range users {
+		var err error
+		if users[i], err = opts.ParseUser(users[i]); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if len(users) > 0 && self {
+		log.Fatal("-user and -self can't be combined")
+	}
+
+	var login string
+	if self {
+		if opts.Provider != "github" {
+			log.Fatal("-self is only supported on GitHub")
+		}
+		if opts.Auth == "" {
+			log.Fatal("-self needs a token, pass -auth or set GH_TOKEN")
+		}
+		var err error
+		login, err = sniffer.AuthenticatedLogin(context.Background(), opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	logger, closeLog, err := newLogger(len(users) == 0 && serve == "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts.Logger = logger
+
+	if opts.CacheDir != "" && cacheMaxAge > 0 {
+		if err := sniffer.PruneCache(opts.CacheDir, cacheMaxAge); err != nil {
+			logger.Warn("could not prune the cache", "err", err)
+		}
+	}
+
+	if serve != "" {
+		if len(users) > 0 || self || listRepos {
+			log.Fatal("-serve takes the user of each request, not -user, -self or -list-repos")
+		}
+		status := runServe(serve)
+		closeLog()
+		os.Exit(status)
+	}
+
+	if listRepos {
+		if self {
+			users = []string{login}
+		}
+		if len(users) == 0 {
+			log.Fatal("-list-repos needs a user or -self")
+		}
+		status := runListRepos(users)
+		closeLog()
+		os.Exit(status)
+	}
+
+	if len(users) > 0 {
+		if !quiet {
+			if text, _ := tier(); text != "" {
+				fmt.Fprintf(os.Stderr, "github-sniffer: %s\n", text)
+			}
+		}
+		status := runCLI(users, emitter)
+		closeLog()
+		os.Exit(status)
+	}
+	defer closeLog()
+
+	restore := saveTerminal(uiOutput)
+	defer func() {
+		if r := recover(); r != nil {
+			restore()
+			panic(r)
+		}
+	}()
+
+	final, err := tea.NewProgram(initialModel(login), programOpts...).Run()
+	if errors.Is(err, tea.ErrInterrupted) {
+		closeLog()
+		os.Exit(130)
+	}
+	if err != nil {
+		restore()
+		closeLog()
+		log.Fatalf("could not run the interactive UI: %v", err)
+	}
+	m, ok := final.(model)
+	if !ok {
+		// Bubble Tea caught a panic, printed it and restored the
+		// terminal; there is no model left.
+		closeLog()
+		os.Exit(1)
+	}
+	if emitter != nil && m.isFinished && m.err == nil {
+		if err := writeResult(emitter, m.sniffResults()); err != nil {
+			log.Fatal(err)
+		}
+		if output != "" {
+			fmt.Fprintf(os.Stderr, "results written to %s\n", output)
+		}
 	}
 }
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/nottgy/github-sniffer/sniffer"
+)
+
+// repoItem is a repo in the -pick-repos list, by full name.
+type repoItem string
+
+func (r repoItem) FilterValue() string { return string(r) }
+
+// repoDelegate draws the repos as checkboxes. checked is shared with the
+// model, so toggling a repo needs no list update.
+type repoDelegate struct {
+	checked map[string]bool
+}
+
+func (d repoDelegate) Height() int                         { return 1 }
+func (d repoDelegate) Spacing() int                        { return 0 }
+func (d repoDelegate) Update(tea.Msg, *list.Model) tea.Cmd { return nil }
+
+func (d repoDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	repo := string(item.(repoItem))
+	box := "[ ]"
+	if d.checked[repo] {
+		box = "[x]"
+	}
+	style := noStyle
+	if index == m.Index() {
+		style = focusedStyle
+	}
+	fmt.Fprint(w, style.Render(box+" "+repo))
+}
+
+var pickerKeys = []key.Binding{
+	key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "toggle")),
+	key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "all/none")),
+	key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "scan")),
+}
+
+// reposMsg carries the repos of every user to pick from.
+type reposMsg struct {
+	repos []string
+	// owner maps each repo to the user it was listed for.
+	owner map[string]string
+}
+
+// listRepoChoices lists the repos of users for the picker. The first
+// failure ends the listing as an errMsg.
+func listRepoChoices(ctx context.Context, users []string) tea.Cmd {
+	return func() tea.Msg {
+		msg := reposMsg{owner: make(map[string]string)}
+		for _, user := range users {
+			repos, err := sniffer.ListRepos(ctx, user, opts)
+			if err != nil {
+				return errMsg{user, err}
+			}
+			for _, repo := range repos {
+				msg.repos = append(msg.repos, repo)
+				msg.owner[repo] = user
+			}
+		}
+		return msg
+	}
+}
+
+// beginScan scans users right away, or lists their repos first: to
+// choose from with -pick-repos, or to count them for -confirm-threshold.
+func (m *model) beginScan(users []string) tea.Cmd {
+	if !pickRepos && confirmThreshold <= 0 {
+		return m.startScan(users, nil)
+	}
+	var ctx context.Context
+	ctx, m.cancel = context.WithCancel(context.Background())
+	m.isLoading, m.listing, m.cancelling = true, true, false
+	m.pickUsers = users
+	return tea.Batch(m.spinner.Tick, listRepoChoices(ctx, users))
+}
+
+// confirmScan scans the listed users right away if their repos are
+// within -confirm-threshold, or asks first. The scan reuses the listing
+// unless it must list the repos itself: GraphQL reads the commits as it
+// lists, and -sample has to know how many repos it picked from.
+func (m *model) confirmScan(msg reposMsg) tea.Cmd {
+	m.isLoading, m.listing = false, false
+	m.listed = nil
+	if !(opts.GraphQL && opts.Auth != "") && opts.Sample == 0 {
+		m.listed = make(map[string][]string)
+		for _, repo := range msg.repos {
+			m.listed[msg.owner[repo]] = append(m.listed[msg.owner[repo]], repo)
+		}
+	}
+	if len(msg.repos) <= confirmThreshold {
+		return m.startScan(m.pickUsers, m.listed)
+	}
+	m.confirming, m.confirmRepos = true, len(msg.repos)
+	return nil
+}
+
+// estimateCalls guesses the requests a REST scan of n repos takes: a
+// page of commits for each, and the pages of the listing.
+func estimateCalls(n int) int {
+	return n + (n+99)/100
+}
+
+// showPicker switches from the loading screen to the repo list.
+func (m *model) showPicker(msg reposMsg) {
+	m.isLoading, m.listing, m.picking = false, false, true
+	m.checked = make(map[string]bool)
+	m.repoOwner = msg.owner
+
+	items := make([]list.Item, len(msg.repos))
+	for i, repo := range msg.repos {
+		items[i] = repoItem(repo)
+	}
+	m.picker = list.New(items, repoDelegate{m.checked}, max(m.width, 60), max(m.height, 10))
+	m.picker.Title = "Pick the repos to scan"
+	styleList(&m.picker)
+	m.picker.SetStatusBarItemName("repo", "repos")
+	m.picker.AdditionalShortHelpKeys = func() []key.Binding { return pickerKeys }
+}
+
+// updatePicker handles the keys of the repo list.
+func (m *model) updatePicker(msg tea.KeyMsg) tea.Cmd {
+	if m.picker.FilterState() == list.Filtering {
+		var cmd tea.Cmd
+		m.picker, cmd = m.picker.Update(msg)
+		return cmd
+	}
+
+	switch msg.String() {
+	case " ":
+		if item, ok := m.picker.SelectedItem().(repoItem); ok {
+			m.checked[string(item)] = !m.checked[string(item)]
+		}
+		return nil
+	case "a":
+		// Check everything shown, or uncheck it all when it already is.
+		visible := m.picker.VisibleItems()
+		all := true
+		for _, item := range visible {
+			all = all && m.checked[string(item.(repoItem))]
+		}
+		for _, item := range visible {
+			m.checked[string(item.(repoItem))] = !all
+		}
+		return nil
+	case "enter":
+		picked := make(map[string][]string)
+		for _, item := range m.picker.Items() {
+			repo := string(item.(repoItem))
+			if m.checked[repo] {
+				picked[m.repoOwner[repo]] = append(picked[m.repoOwner[repo]], repo)
+			}
+		}
+		if len(picked) == 0 {
+			return m.picker.NewStatusMessage("pick at least one repo")
+		}
+		// Users without any repo picked aren't scanned.
+		var users []string
+		for _, user := range m.pickUsers {
+			if len(picked[user]) > 0 {
+				users = append(users, user)
+			}
+		}
+		m.picking = false
+		return m.startScan(users, picked)
+	}
+
+	var cmd tea.Cmd
+	m.picker, cmd = m.picker.Update(msg)
+	return cmd
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/nottgy/github-sniffer/sniffer"
+)
+
+// Emitter writes the Results of one run, one per scanned user, in some
+// output format.
+type Emitter interface {
+	Emit(w io.Writer, rs []sniffer.Result) error
+}
+
+var emitters = map[string]Emitter{
+	"text":     textEmitter{},
+	"plain":    plainEmitter{},
+	"json":     jsonEmitter{},
+	"jsonl":    jsonlEmitter{},
+	"csv":      csvEmitter{},
+	"html":     htmlEmitter{},
+	"markdown": markdownEmitter{},
+	"matrix":   matrixEmitter{},
+	"vcf":      vcfEmitter{},
+}
+
+// streamEmitter is an Emitter that can also write the Result of each
+// user on its own, as soon as its scan is done.
+type streamEmitter interface {
+	Emitter
+	EmitOne(w io.Writer, r sniffer.Result) error
+}
+
+// writeResult emits rs to the -output file, creating its directory as
+// needed, or to stdout without one.
+func writeResult(emitter Emitter, rs []sniffer.Result) error {
+	w, err := openOutput()
+	if err != nil {
+		return err
+	}
+	if err := emitter.Emit(w, rs); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// openOutput creates the -output file and its directory, or returns
+// stdout without one. Closing stdout is left to the exit.
+func openOutput() (io.WriteCloser, error) {
+	if output == "" {
+		return nopCloser{os.Stdout}, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+		return nil, outputError(err)
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return nil, outputError(err)
+	}
+	return f, nil
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// outputError explains why the -output file could not be written.
+func outputError(err error) error {
+	if errors.Is(err, fs.ErrPermission) {
+		return fmt.Errorf("can't write %s: permission denied", output)
+	}
+	return fmt.Errorf("can't write %s: %w", output, err)
+}
+
+// Each format is rendered by the sniffer.Results method of its name.
+type (
+	textEmitter     struct{}
+	plainEmitter    struct{}
+	jsonEmitter     struct{}
+	jsonlEmitter    struct{}
+	csvEmitter      struct{}
+	htmlEmitter     struct{}
+	markdownEmitter struct{}
+	matrixEmitter   struct{}
+	vcfEmitter      struct{}
+)
+
+func (textEmitter) Emit(w io.Writer, rs []sniffer.Result) error {
+	return sniffer.Results(rs).WriteText(w)
+}
+
+func (plainEmitter) Emit(w io.Writer, rs []sniffer.Result) error {
+	return sniffer.Results(rs).WritePlain(w)
+}
+
+func (jsonEmitter) Emit(w io.Writer, rs []sniffer.Result) error {
+	return sniffer.Results(rs).WriteJSON(w)
+}
+
+func (jsonlEmitter) Emit(w io.Writer, rs []sniffer.Result) error {
+	return sniffer.Results(rs).WriteJSONL(w)
+}
+
+func (jsonlEmitter) EmitOne(w io.Writer, r sniffer.Result) error {
+	return r.WriteJSONL(w)
+}
+
+func (csvEmitter) Emit(w io.Writer, rs []sniffer.Result) error {
+	return sniffer.Results(rs).WriteCSV(w)
+}
+
+func (htmlEmitter) Emit(w io.Writer, rs []sniffer.Result) error {
+	return sniffer.Results(rs).WriteHTML(w)
+}
+
+func (markdownEmitter) Emit(w io.Writer, rs []sniffer.Result) error {
+	return sniffer.Results(rs).WriteMarkdown(w)
+}
+
+func (matrixEmitter) Emit(w io.Writer, rs []sniffer.Result) error {
+	return sniffer.Results(rs).WriteMatrix(w)
+}
+
+func (vcfEmitter) Emit(w io.Writer, rs []sniffer.Result) error {
+	return sniffer.Results(rs).WriteVCF(w)
+}
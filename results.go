@@ -0,0 +1,497 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+
+	"github.com/nottgy/github-sniffer/sniffer"
+)
+
+// Columns of the results table, in the order sortBy cycles through them.
+const (
+	sortByCount = iota
+	sortByEmail
+	sortByName
+	sortByRepo
+	sortByLastActive
+	sortColumns
+)
+
+func resultsTableStyles() table.Styles {
+	s := table.DefaultStyles()
+	s.Header = s.Header.
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(blurredStyle.GetForeground()).
+		BorderBottom(true).
+		Bold(false)
+	s.Selected = s.Selected.Foreground(focusedStyle.GetForeground()).Bold(true)
+	return s
+}
+
+func newResultsTable() table.Model {
+	return table.New(table.WithFocused(true), table.WithStyles(resultsTableStyles()))
+}
+
+// sorted returns the contacts ordered by the column chosen with s. Commit
+// and repo counts sort highest first, dates latest first, everything else
+// alphabetically.
+func (m model) sorted() []sniffer.Contact {
+	data := slices.Clone(m.data)
+	slices.SortStableFunc(data, func(a, b sniffer.Contact) int {
+		switch m.sortBy {
+		case sortByEmail:
+			return cmp.Compare(strings.ToLower(a.Email), strings.ToLower(b.Email))
+		case sortByName:
+			return cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		case sortByRepo:
+			return cmp.Or(cmp.Compare(len(b.Repos), len(a.Repos)), cmp.Compare(firstRepo(a), firstRepo(b)))
+		case sortByLastActive:
+			return b.LastActive.Compare(a.LastActive)
+		}
+		return cmp.Compare(b.Count, a.Count)
+	})
+	return data
+}
+
+func firstRepo(c sniffer.Contact) string {
+	if len(c.Repos) == 0 {
+		return ""
+	}
+	return c.Repos[0]
+}
+
+// resultsColumns splits the terminal width between the columns; cells
+// that don't fit are truncated by the table.
+func (m model) resultsColumns() []table.Column {
+	const count, date = 9, 13
+	// Every cell is padded by one space on each side.
+	w := max(m.width, 60) - count - date - 5*2
+	email := w * 4 / 10
+	name := w * 3 / 10
+
+	cols := []table.Column{
+		{Title: "Email", Width: email},
+		{Title: "Name", Width: name},
+		{Title: "Commits", Width: count},
+		{Title: "Last active", Width: date},
+		{Title: "Repos", Width: w - email - name},
+	}
+	sorted := map[int]int{sortByEmail: 0, sortByName: 1, sortByCount: 2, sortByLastActive: 3, sortByRepo: 4}[m.sortBy]
+	cols[sorted].Title += " ▾"
+	return cols
+}
+
+// reposCell lists the repos c was found in, or just how many there are
+// when the list doesn't fit in width.
+func reposCell(c sniffer.Contact, width int) string {
+	repos := strings.Join(c.Repos, ", ")
+	if len(c.Repos) > 1 && len(repos) > width {
+		return fmt.Sprintf("found in %d repos", len(c.Repos))
+	}
+	return repos
+}
+
+func (m model) resultsRows() []table.Row {
+	width := m.resultsColumns()[4].Width
+	data := m.sorted()
+	rows := make([]table.Row, len(data))
+	for i, c := range data {
+		email := c.Email
+		if c.Placeholder {
+			email += " (placeholder)"
+		}
+		if c.Login != "" {
+			email += " @" + c.Login
+		}
+		if signed := c.SignedLabel(); signed != "" {
+			email += " " + signed
+		}
+		rows[i] = table.Row{email, c.DisplayName(), strconv.Itoa(c.Count), c.LastActiveDate(), reposCell(c, width)}
+	}
+	return rows
+}
+
+// tableView is the results table with the rows of placeholder addresses
+// dimmed. The table styles every row alike, so they are found by how it
+// renders them; the selected row keeps its own style.
+func (m model) tableView() string {
+	view := m.results.View()
+	styles, cols, rows := resultsTableStyles(), m.results.Columns(), m.results.Rows()
+	dim := make(map[string]bool)
+	for i, c := range m.sorted() {
+		if c.Placeholder && i < len(rows) {
+			dim[renderRow(styles, cols, rows[i])] = true
+		}
+	}
+	if len(dim) == 0 {
+		return view
+	}
+	lines := strings.Split(view, "\n")
+	for i, line := range lines {
+		if dim[strings.TrimRight(line, " ")] {
+			lines[i] = blurredStyle.Render(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderRow renders row as the table does an unselected one, trailing
+// spaces left out.
+func renderRow(styles table.Styles, cols []table.Column, row table.Row) string {
+	cells := make([]string, 0, len(cols))
+	for i, value := range row {
+		if i >= len(cols) || cols[i].Width <= 0 {
+			continue
+		}
+		style := lipgloss.NewStyle().Width(cols[i].Width).MaxWidth(cols[i].Width).Inline(true)
+		cells = append(cells, styles.Cell.Render(style.Render(runewidth.Truncate(value, cols[i].Width, "…"))))
+	}
+	return strings.TrimRight(lipgloss.JoinHorizontal(lipgloss.Top, cells...), " ")
+}
+
+// failedView lists the repos that could not be scanned below the table.
+func (m model) failedView() string {
+	if len(m.failed) == 0 {
+		return ""
+	}
+	s := fmt.Sprintf("\n%d repos could not be scanned:", len(m.failed))
+	for _, f := range m.failed {
+		s += "\n" + blurredStyle.Render(f.Error())
+	}
+	return s
+}
+
+// cutShortView names the repos of the user shown that -max-commits
+// stopped reading early.
+func (m model) cutShortView() string {
+	if m.current >= len(m.scans) {
+		return ""
+	}
+	cut := m.scans[m.current].cutShort
+	if len(cut) == 0 {
+		return ""
+	}
+	return "\n" + errorStyle.Render(fmt.Sprintf("%d repos were read only up to -max-commits: ", len(cut))) + blurredStyle.Render(strings.Join(cut, ", "))
+}
+
+// summaryView sums up the scan of the user shown.
+func (m model) summaryView() string {
+	r := m.scans[m.current]
+	s := fmt.Sprintf(
+		"%d unique emails from %d repos in %s",
+		len(m.data), m.total[r.user], m.elapsed.Round(100*time.Millisecond),
+	)
+	if r.stats.Requests > 0 || r.stats.CacheHits > 0 {
+		s += helpStyle.Render(fmt.Sprintf(" · %d requests, %d from cache", r.stats.Requests, r.stats.CacheHits))
+	}
+	return s
+}
+
+// layoutResults fits the results table to the data and the terminal.
+func (m *model) layoutResults() {
+	m.results.SetColumns(m.resultsColumns())
+	m.results.SetRows(m.resultsRows())
+
+	// Leave room for the username, the summary, the help line, the
+	// failures and the repos cut short.
+	height := len(m.data) + 2
+	if m.height > 0 {
+		height = m.height - 3 - lipgloss.Height(m.failedView()) - lipgloss.Height(m.cutShortView()) + 1
+	}
+	m.results.SetHeight(max(height, 3))
+	m.results.SetWidth(max(m.width, 60))
+}
+
+// emails returns the found addresses, one per line.
+func (m model) emails() string {
+	emails := make([]string, len(m.data))
+	for i, c := range m.sorted() {
+		emails[i] = c.Email
+	}
+	return strings.Join(emails, "\n")
+}
+
+// userLabel names the user of r, with the new name if it was renamed.
+func userLabel(r userResult) string {
+	label := r.user
+	if r.renamedTo != "" {
+		label = fmt.Sprintf("%s (redirected to %s)", r.user, r.renamedTo)
+	}
+	if r.sampledFrom > 0 {
+		label += fmt.Sprintf(" (sample of %d of %d repos)", opts.Sample, r.sampledFrom)
+	}
+	if r.partial {
+		label += " (partial — scan cancelled)"
+	}
+	return label
+}
+
+// usersView lists the scanned users with the one shown highlighted.
+func (m model) usersView() string {
+	if len(m.scans) == 1 {
+		return userLabel(m.scans[0])
+	}
+	users := make([]string, len(m.scans))
+	for i, r := range m.scans {
+		style := blurredStyle
+		if i == m.current {
+			style = focusedStyle
+		}
+		users[i] = style.Render(userLabel(r))
+	}
+	return strings.Join(users, "  ")
+}
+
+// domainsView lists the domains of the shown addresses with their counts,
+// as many as fit on screen.
+func (m model) domainsView() string {
+	domains := sniffer.CountDomains(m.data)
+	width := 0
+	for _, d := range domains {
+		width = max(width, len(d.Domain))
+	}
+	// Leave room for the header, the title and the help line.
+	fit := len(domains)
+	if m.height > 0 {
+		fit = max(m.height-5, 1)
+	}
+
+	var b strings.Builder
+	b.WriteString(focusedStyle.Render("Emails by domain") + "\n")
+	for i, d := range domains {
+		if i == fit && len(domains) > fit {
+			b.WriteString(helpStyle.Render(fmt.Sprintf("… %d more", len(domains)-fit)) + "\n")
+			break
+		}
+		fmt.Fprintf(&b, "%-*s %5d\n", width, d.Domain, d.Count)
+	}
+	return b.String()
+}
+
+// matrixView tabulates the commits of the shown addresses per repo, in
+// the order of the table, with as many repos as fit the width.
+func (m model) matrixView() string {
+	contacts := m.sorted()
+	repos := sniffer.RepoColumns(contacts)
+	emailWidth := 0
+	for _, c := range contacts {
+		emailWidth = max(emailWidth, len(c.Email))
+	}
+	// Each repo gets a column as wide as its name, at most 20.
+	widths := make([]int, len(repos))
+	for i, repo := range repos {
+		widths[i] = max(min(len(repo), 20), 5)
+	}
+	shown := len(repos)
+	if m.width > 0 {
+		used := emailWidth
+		for i, w := range widths {
+			if used+1+w > m.width {
+				shown = i
+				break
+			}
+			used += 1 + w
+		}
+	}
+	fit := len(contacts)
+	if m.height > 0 {
+		fit = max(m.height-6, 1)
+	}
+
+	var b strings.Builder
+	b.WriteString(focusedStyle.Render("Commits per repo") + "\n")
+	if len(repos) == 0 {
+		b.WriteString(helpStyle.Render("No breakdown for these results.") + "\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%-*s", emailWidth, "")
+	for i, repo := range repos[:shown] {
+		if len(repo) > widths[i] {
+			// Keep the repo name, the end of the full name.
+			repo = ".." + repo[len(repo)-widths[i]+2:]
+		}
+		fmt.Fprintf(&b, " %*s", widths[i], repo)
+	}
+	b.WriteString("\n")
+	for i, c := range contacts {
+		if i == fit && len(contacts) > fit {
+			b.WriteString(helpStyle.Render(fmt.Sprintf("… %d more", len(contacts)-fit)) + "\n")
+			break
+		}
+		fmt.Fprintf(&b, "%-*s", emailWidth, c.Email)
+		for j, repo := range repos[:shown] {
+			cell := "-"
+			if n := c.RepoCommits[repo]; n > 0 {
+				cell = strconv.Itoa(n)
+			}
+			fmt.Fprintf(&b, " %*s", widths[j], cell)
+		}
+		b.WriteString("\n")
+	}
+	if shown < len(repos) {
+		b.WriteString(helpStyle.Render(fmt.Sprintf("%d more repos don't fit", len(repos)-shown)) + "\n")
+	}
+	return b.String()
+}
+
+func (m model) resultsView() string {
+	keys := "↑/↓ pgup/pgdn to move • s to sort • d for domains • m for repos • enter to copy one • c to copy all • r to search again • h for history • ? for help • q to quit"
+	if len(m.scans) > 1 {
+		keys = "tab to switch user • " + keys
+	}
+	help := helpStyle.Render(keys)
+	if m.status != "" {
+		help += " " + focusedStyle.Render(m.status)
+	}
+	header := m.usersView()
+	if m.budget.Limit > 0 {
+		header += "  " + helpStyle.Render(m.budget.String())
+	}
+	if err := m.scans[m.current].err; err != nil {
+		return fmt.Sprintf("%s\n\n%s\n\n%s", header, describeError(err), help)
+	}
+	if m.showDomains {
+		return fmt.Sprintf("%s\n\n%s\n%s", header, m.domainsView(), help)
+	}
+	if m.showMatrix {
+		return fmt.Sprintf("%s\n\n%s\n%s", header, m.matrixView(), help)
+	}
+	return fmt.Sprintf("%s\n%s%s%s\n%s\n%s", header, m.tableView(), m.failedView(), m.cutShortView(), m.summaryView(), help)
+}
+
+// addLive merges the contacts of a finished repo into the live list
+// shown while scanning. The results screen shows the real merge, so
+// counting commits per address is enough here.
+func (m *model) addLive(contacts []sniffer.Contact) {
+	for _, c := range contacts {
+		if i, ok := m.liveIndex[c.Email]; ok {
+			m.live[i].Count += c.Count
+			continue
+		}
+		m.liveIndex[c.Email] = len(m.live)
+		m.live = append(m.live, c)
+	}
+}
+
+// maxRepoLines caps the repo list of the loading screen.
+const maxRepoLines = 8
+
+type repoState int
+
+const (
+	repoRunning repoState = iota
+	repoDone
+	repoFailed
+)
+
+// repoStatus is a repo on the loading screen.
+type repoStatus struct {
+	repo   string
+	state  repoState
+	emails int
+}
+
+// startRepo adds repo to the loading screen as running.
+func (m *model) startRepo(repo string) {
+	m.repoIndex[repo] = len(m.repos)
+	m.repos = append(m.repos, repoStatus{repo: repo})
+}
+
+// finishRepo marks repo done with emails found, or failed. GraphQL scans
+// don't report starts, so repo may show up only now.
+func (m *model) finishRepo(repo string, emails int, err error) {
+	i, ok := m.repoIndex[repo]
+	if !ok {
+		m.startRepo(repo)
+		i = len(m.repos) - 1
+	}
+	m.repos[i].emails = emails
+	m.repos[i].state = repoDone
+	if err != nil {
+		m.repos[i].state = repoFailed
+	}
+}
+
+// shownRepos picks the repos of the loading screen, in the order they
+// started: the running ones first, then the latest to finish, so the
+// list follows the scan without losing the slow repos.
+func (m model) shownRepos() []int {
+	var running, finished []int
+	for i, r := range m.repos {
+		if r.state == repoRunning {
+			running = append(running, i)
+		} else {
+			finished = append(finished, i)
+		}
+	}
+	shown := running[:min(len(running), maxRepoLines)]
+	room := maxRepoLines - len(shown)
+	shown = append(shown, finished[max(len(finished)-room, 0):]...)
+	slices.Sort(shown)
+	return shown
+}
+
+// reposView lists the repos of the running scan with a spinner next to
+// the ones being read and a mark next to the finished ones.
+func (m model) reposView() string {
+	if len(m.repos) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n")
+	shown := m.shownRepos()
+	for _, i := range shown {
+		r := m.repos[i]
+		switch r.state {
+		case repoRunning:
+			fmt.Fprintf(&b, "\n%s %s", m.spinner.View(), r.repo)
+		case repoDone:
+			fmt.Fprintf(&b, "\n%s %s %s", focusedStyle.Render("✓"), r.repo, helpStyle.Render(fmt.Sprintf("%d emails", r.emails)))
+		case repoFailed:
+			fmt.Fprintf(&b, "\n%s %s %s", errorStyle.Render("✗"), r.repo, helpStyle.Render("failed"))
+		}
+	}
+	if hidden := len(m.repos) - len(shown); hidden > 0 {
+		b.WriteString("\n" + helpStyle.Render(fmt.Sprintf("… %d more", hidden)))
+	}
+	return b.String()
+}
+
+// reposHeight is how many lines reposView takes.
+func (m model) reposHeight() int {
+	if len(m.repos) == 0 {
+		return 0
+	}
+	lines := 1 + min(len(m.repos), maxRepoLines)
+	if len(m.repos) > maxRepoLines {
+		lines++
+	}
+	return lines
+}
+
+// liveView lists the latest addresses found while scanning, as many as
+// fit below the progress bar and the repos.
+func (m model) liveView() string {
+	if len(m.live) == 0 {
+		return ""
+	}
+	fit := 10
+	if m.height > 0 {
+		// Leave room for the progress bar, the budget, the repos and the
+		// heading.
+		fit = max(m.height-7-m.reposHeight(), 1)
+	}
+	s := fmt.Sprintf("\n\n%d emails so far", len(m.live))
+	for _, c := range m.live[max(len(m.live)-fit, 0):] {
+		s += "\n" + blurredStyle.Render(c.String())
+	}
+	return s
+}
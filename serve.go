@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/nottgy/github-sniffer/sniffer"
+)
+
+// maxServeScans is how many users -serve scans at the same time. Further
+// requests wait for a slot; the repos of all of them still share the
+// -concurrency limit.
+const maxServeScans = 4
+
+// scanServer answers GET /scan?user=NAME with the JSON report of the
+// scan of NAME.
+type scanServer struct {
+	slots chan struct{}
+}
+
+func (s scanServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("only GET is supported"))
+		return
+	}
+	user, err := opts.ParseUser(r.URL.Query().Get("user"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	o := opts
+	// A token in the request takes the place of the one the server was
+	// started with.
+	if token := requestToken(r); token != "" {
+		o.Auth = token
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-r.Context().Done():
+		return
+	}
+
+	res, err := sniffer.Sniff(r.Context(), user, o)
+	if err != nil {
+		if r.Context().Err() != nil {
+			// The client is gone, nobody to answer.
+			return
+		}
+		opts.Logger.Warn("scan failed", "user", user, "err", err)
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	opts.Logger.Info("scanned", "user", user, "emails", len(res.Contacts), "failed", len(res.Failed))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := (jsonEmitter{}).Emit(w, []sniffer.Result{res}); err != nil {
+		opts.Logger.Warn("could not write the response", "user", user, "err", err)
+	}
+}
+
+// requestToken returns the token of an "Authorization: Bearer" or
+// "token" header, "" without one.
+func requestToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || (!strings.EqualFold(scheme, "bearer") && !strings.EqualFold(scheme, "token")) {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// errorStatus maps a scan error to the HTTP status answering it.
+func errorStatus(err error) int {
+	var (
+		rateLimit sniffer.RateLimitError
+		notFound  sniffer.UserNotFoundError
+		auth      sniffer.AuthError
+	)
+	switch {
+	case errors.As(err, &notFound):
+		return http.StatusNotFound
+	case errors.As(err, &rateLimit):
+		return http.StatusTooManyRequests
+	case errors.As(err, &auth):
+		return auth.Code
+	}
+	return http.StatusBadGateway
+}
+
+// writeError answers with status and err as a JSON {"error": ...} body.
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// runServe serves scans on addr until interrupted and returns the
+// process exit status.
+func runServe(addr string) int {
+	mux := http.NewServeMux()
+	mux.Handle("/scan", scanServer{slots: make(chan struct{}, maxServeScans)})
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		<-ctx.Done()
+		// Let the scans in flight finish, within reason.
+		shutdown, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+
+	log.Printf("listening on %s", addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Print(err)
+		return 1
+	}
+	<-closed
+	return 0
+}
@@ -0,0 +1,143 @@
+package sniffer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	neturl "net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBitbucketURL is the API root of bitbucket.org.
+const DefaultBitbucketURL = "https://api.bitbucket.org/2.0"
+
+// curl https://api.bitbucket.org/2.0/repositories/atlassian
+// curl https://api.bitbucket.org/2.0/repositories/atlassian/python-bitbucket/commits
+
+type BitbucketRepoDataPiece struct {
+	FullName string `json:"full_name"`
+	// Parent is only present on forks.
+	Parent *struct{} `json:"parent"`
+}
+
+type BitbucketCommitDataPiece struct {
+	Date   time.Time `json:"date"`
+	Author struct {
+		// Raw is the git author, "Name <email>".
+		Raw string `json:"raw"`
+	} `json:"author"`
+}
+
+// bitbucketPage is a page of a Bitbucket listing, which links the next
+// page in the body rather than a Link header.
+type bitbucketPage[T any] struct {
+	Values []T    `json:"values"`
+	Next   string `json:"next"`
+}
+
+// bitbucket fetches from Bitbucket Cloud; users are workspaces there.
+// Commits only carry their author, and there is no contributor summary.
+type bitbucket struct {
+	*scanner
+}
+
+func (b bitbucket) getRepos(ctx context.Context, user string) ([]string, error) {
+	ctx, cancel := b.listingDeadline(ctx)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/repositories/%s?pagelen=100", b.baseAPI, neturl.PathEscape(user))
+	if !b.opts.IncludePrivate {
+		// A token sees the private repos of its workspaces too.
+		url += "&q=" + neturl.QueryEscape("is_private = false")
+	}
+	data := []string{}
+	for url != "" {
+		var page bitbucketPage[BitbucketRepoDataPiece]
+		if _, err := b.getJSON(ctx, url, &page); err != nil {
+			return data, userNotFound(user, err)
+		}
+
+		for _, d := range page.Values {
+			if (b.opts.NoForks && d.Parent != nil) || b.excluded(d.FullName) {
+				continue
+			}
+			data = append(data, d.FullName)
+			if b.opts.MaxRepos > 0 && len(data) >= b.opts.MaxRepos {
+				return data, nil
+			}
+		}
+
+		url = page.Next
+	}
+
+	return data, nil
+}
+
+// getRepoEmails collects the contacts of fullName from the commit
+// authors of all its branches, newest first. Bitbucket can't filter by
+// date, so the commits after Options.Until are skipped and the listing
+// stops at the first one before Options.Since.
+func (b bitbucket) getRepoEmails(ctx context.Context, fullName string) ([]Contact, error) {
+	data := []Contact{}
+	seen := make(map[string]int)
+	read := 0
+	url := fmt.Sprintf("%s/repositories/%s/commits?pagelen=100", b.baseAPI, fullName)
+	for url != "" {
+		var page bitbucketPage[BitbucketCommitDataPiece]
+		if _, err := b.getJSON(ctx, url, &page); err != nil {
+			return data, err
+		}
+
+		commits := make([]Commit, 0, len(page.Values))
+		done := false
+		for _, d := range page.Values {
+			if !b.opts.Since.IsZero() && d.Date.Before(b.opts.Since) {
+				done = true
+				break
+			}
+			if !b.opts.Until.IsZero() && d.Date.After(b.opts.Until) {
+				continue
+			}
+			if b.opts.MaxCommits > 0 && read >= b.opts.MaxCommits {
+				// d is in range, and left unread.
+				b.cutShort(fullName)
+				done = true
+				break
+			}
+			read++
+			name, email := parseRawAuthor(d.Author.Raw)
+			commits = append(commits, Commit{Author: Author{Name: name, Email: email, Date: d.Date}})
+		}
+		data = mergeContacts(data, seen, b.dedupKey, b.commitContacts(fullName, commits))
+
+		if done {
+			break
+		}
+		url = page.Next
+	}
+
+	return data, nil
+}
+
+// getRepoContributors fails: Bitbucket has no contributor summary, and
+// newScanner turns the contributors source down before it gets here.
+func (b bitbucket) getRepoContributors(ctx context.Context, fullName string) ([]Contact, error) {
+	return nil, errors.New("bitbucket has no contributors listing")
+}
+
+// parseRawAuthor splits a git author as Bitbucket reports it, "Name
+// <email>", into name and email. Anything unparsable is taken as a bare
+// name.
+func parseRawAuthor(raw string) (name, email string) {
+	if addr, err := mail.ParseAddress(raw); err == nil {
+		return addr.Name, addr.Address
+	}
+	// Git allows what RFC 5322 doesn't, like unquoted dots in names.
+	open, closing := strings.LastIndexByte(raw, '<'), strings.LastIndexByte(raw, '>')
+	if open < 0 || closing < open {
+		return strings.TrimSpace(raw), ""
+	}
+	return strings.TrimSpace(raw[:open]), strings.TrimSpace(raw[open+1 : closing])
+}
@@ -0,0 +1,100 @@
+package sniffer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Budget is what is left of the core API rate limit.
+type Budget struct {
+	Remaining int
+	Limit     int
+	Reset     time.Time
+}
+
+func (b Budget) String() string {
+	return fmt.Sprintf("API budget: %d/%d remaining", b.Remaining, b.Limit)
+}
+
+// budgetTracker keeps the lowest remaining count GitHub reported for the
+// current rate limit window. Responses arrive out of order from the
+// workers, so the last one seen isn't necessarily the latest.
+type budgetTracker struct {
+	mu sync.Mutex
+	b  Budget
+}
+
+// record notes the budget the rate limit headers h report, and tells
+// whether that changed it.
+func (t *budgetTracker) record(h http.Header) (Budget, bool) {
+	if resource := h.Get("X-RateLimit-Resource"); resource != "" && resource != "core" {
+		return Budget{}, false
+	}
+	limit, err := strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return Budget{}, false
+	}
+	remaining, _ := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	resetUnix, _ := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	reset := time.Unix(resetUnix, 0)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.b.Limit != 0 && !reset.After(t.b.Reset) && remaining >= t.b.Remaining {
+		return t.b, false
+	}
+	t.b = Budget{Remaining: remaining, Limit: limit, Reset: reset}
+	return t.b, true
+}
+
+// get returns the budget as of the responses so far, the zero Budget
+// before any.
+func (t *budgetTracker) get() Budget {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.b
+}
+
+// recordBudget tracks the budget reported by the headers h of a response
+// and passes it on to Options.BudgetChanged when it changed.
+func (s *scanner) recordBudget(h http.Header) {
+	if b, changed := s.budget.record(h); changed && s.opts.BudgetChanged != nil {
+		s.opts.BudgetChanged(b)
+	}
+}
+
+// FetchBudget asks GitHub for the core rate limit of opts.Auth, or of
+// anonymous requests without one. /rate_limit doesn't count against the
+// limit.
+func FetchBudget(ctx context.Context, opts Options) (Budget, error) {
+	if opts.Provider != "" && opts.Provider != "github" {
+		return Budget{}, fmt.Errorf("rate limits are only known on GitHub")
+	}
+	// The answer changes with every request, not worth caching.
+	opts.CacheDir = ""
+	s, err := newScanner(opts)
+	if err != nil {
+		return Budget{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+
+	var data struct {
+		Resources struct {
+			Core struct {
+				Limit     int   `json:"limit"`
+				Remaining int   `json:"remaining"`
+				Reset     int64 `json:"reset"`
+			} `json:"core"`
+		} `json:"resources"`
+	}
+	if _, err := s.getJSON(ctx, s.baseAPI+"/rate_limit", &data); err != nil {
+		return Budget{}, err
+	}
+	core := data.Resources.Core
+	return Budget{Remaining: core.Remaining, Limit: core.Limit, Reset: time.Unix(core.Reset, 0)}, nil
+}
@@ -0,0 +1,138 @@
+package sniffer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cacheEntry is a successful API response as kept on disk.
+type cacheEntry struct {
+	Body []byte `json:"body"`
+	// Next is the URL of the page after this one, "" on the last page.
+	Next string `json:"next,omitempty"`
+	// ETag revalidates the entry once it is older than CacheTTL.
+	ETag string `json:"etag,omitempty"`
+}
+
+// DefaultCacheDir returns the github-sniffer directory in the user cache
+// directory.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "github-sniffer"), nil
+}
+
+// PruneCache deletes the responses cached in dir that were neither
+// written nor revalidated for maxAge, and temporary files left behind by
+// interrupted writes. A missing dir has nothing to prune.
+func PruneCache(dir string, maxAge time.Duration) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".json" && ext != ".tmp") {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil || time.Since(fi.ModTime()) < maxAge {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, e.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// cachePath returns the file caching the response to url. The token is
+// part of the key: another token may see different repos.
+func (s *scanner) cachePath(url string) string {
+	sum := sha256.Sum256([]byte(s.opts.Auth + "\x00" + url))
+	return filepath.Join(s.cacheDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// listingKey marks the context of a repo listing.
+type listingKey struct{}
+
+// listing returns ctx marked as listing repos, so its responses are
+// cached for ReposCacheTTL.
+func listing(ctx context.Context) context.Context {
+	return context.WithValue(ctx, listingKey{}, true)
+}
+
+// cacheTTL is how long the cached responses of requests made with ctx
+// are used as they are.
+func (s *scanner) cacheTTL(ctx context.Context) time.Duration {
+	if ctx.Value(listingKey{}) != nil && s.opts.ReposCacheTTL > 0 {
+		return s.opts.ReposCacheTTL
+	}
+	return s.opts.CacheTTL
+}
+
+// readCache returns the cached response to url, if any, and whether it
+// is younger than ttl and so can be used without asking GitHub.
+func (s *scanner) readCache(url string, ttl time.Duration) (e cacheEntry, fresh, ok bool) {
+	if s.cacheDir == "" {
+		return cacheEntry{}, false, false
+	}
+	path := s.cachePath(url)
+	fi, err := os.Stat(path)
+	if err != nil {
+		return cacheEntry{}, false, false
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return cacheEntry{}, false, false
+	}
+	if err := json.Unmarshal(b, &e); err != nil {
+		return cacheEntry{}, false, false
+	}
+	return e, time.Since(fi.ModTime()) < ttl, true
+}
+
+// writeCache saves the response to url, which also restarts its TTL. The
+// cache only saves requests, so failing to write it isn't worth failing
+// the scan over.
+func (s *scanner) writeCache(url string, e cacheEntry) {
+	if s.cacheDir == "" {
+		return
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(s.cacheDir, 0o700); err != nil {
+		return
+	}
+	// Write and rename so concurrent readers never see half a file.
+	tmp, err := os.CreateTemp(s.cacheDir, "*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if err := os.Rename(tmp.Name(), s.cachePath(url)); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
@@ -0,0 +1,255 @@
+package sniffer
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Contact is one identity found in commit metadata.
+type Contact struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email"`
+	// Aliases are the other names the identity used, most common first.
+	Aliases []string `json:"aliases,omitempty"`
+	// Login is the GitHub account behind a noreply address, or with
+	// Options.ResolveLogins the one found by searching the address.
+	Login string `json:"login,omitempty"`
+	// Count is the number of commits the identity authored or committed.
+	Count int `json:"count"`
+	// Signed is how many of those commits carry a signature GitHub
+	// verified. Contributor summaries and GitLab don't tell, so it is
+	// always 0 there.
+	Signed int `json:"signed"`
+	// Repos lists the repositories the identity was seen in.
+	Repos []string `json:"repos,omitempty"`
+	// RepoCommits breaks Count down by repository.
+	RepoCommits map[string]int `json:"repo_commits,omitempty"`
+	// LastActive is the date of the latest commit of the identity, zero
+	// when the source has no dates.
+	LastActive time.Time `json:"last_active"`
+	// Placeholder is set for the addresses git falls back to without a
+	// configured user.email, documentation examples and the matches of
+	// Options.Placeholders.
+	Placeholder bool `json:"placeholder,omitempty"`
+
+	// names counts how often each name was used with the identity, so
+	// Name can be the most common one.
+	names map[string]int
+}
+
+// nameCounts returns how often c was seen under each name.
+func (c Contact) nameCounts() map[string]int {
+	if c.names != nil {
+		return c.names
+	}
+	if c.Name == "" {
+		return map[string]int{}
+	}
+	return map[string]int{c.Name: 1}
+}
+
+// repoCounts returns the commits of c per repo. A contact fresh from a
+// single repo has them all there.
+func (c Contact) repoCounts() map[string]int {
+	if c.RepoCommits != nil {
+		return c.RepoCommits
+	}
+	if len(c.Repos) != 1 {
+		return map[string]int{}
+	}
+	return map[string]int{c.Repos[0]: c.Count}
+}
+
+// DisplayName returns Name followed by the aliases, as in
+// "Jane Doe (aka jdoe, Jane)".
+func (c Contact) DisplayName() string {
+	if len(c.Aliases) == 0 {
+		return c.Name
+	}
+	return fmt.Sprintf("%s (aka %s)", c.Name, strings.Join(c.Aliases, ", "))
+}
+
+// setAliases lists the names of c other than Name, most used first and
+// ties by name.
+func (c *Contact) setAliases() {
+	c.Aliases = nil
+	for name := range c.names {
+		if name != c.Name {
+			c.Aliases = append(c.Aliases, name)
+		}
+	}
+	slices.SortFunc(c.Aliases, func(a, b string) int {
+		if n := c.names[b] - c.names[a]; n != 0 {
+			return n
+		}
+		return strings.Compare(a, b)
+	})
+}
+
+func (c Contact) String() string {
+	s := c.Email
+	if c.Name != "" {
+		s = fmt.Sprintf("%s <%s>", c.DisplayName(), c.Email)
+	}
+	if c.Login != "" {
+		s += " @" + c.Login
+	}
+	return s
+}
+
+// normalizeEmail trims email and, unless Options.CaseSensitive is set,
+// lowercases it, so "Foo@Example.com " and "foo@example.com" are one
+// contact. Domains are case insensitive anyway, and hardly any mail server
+// treats the local part differently.
+func (s *scanner) normalizeEmail(email string) string {
+	if s.opts.CaseSensitive {
+		return email
+	}
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// isNoreply reports whether email is one of the addresses GitHub hands
+// out to hide the real one.
+func isNoreply(email string) bool {
+	return strings.HasSuffix(strings.ToLower(strings.TrimSpace(email)), "@users.noreply.github.com")
+}
+
+// botEmails are addresses automation commits under without a [bot]
+// marker in the name.
+var botEmails = []string{
+	"actions@github.com",
+	"action@github.com",
+	"noreply@github.com",
+	"support@dependabot.com",
+	"bot@renovateapp.com",
+	"renovate@whitesourcesoftware.com",
+	"bot@greenkeeper.io",
+	"snyk-bot@snyk.io",
+	"imgbot@users.noreply.github.com",
+}
+
+// isBot guesses from name and email whether an identity is automation:
+// GitHub App accounts are named "something[bot]", other bots commit
+// under a few well known addresses.
+func isBot(name, email string) bool {
+	email = strings.ToLower(strings.TrimSpace(email))
+	local, _, _ := strings.Cut(email, "@")
+	return strings.HasSuffix(strings.TrimSpace(name), "[bot]") ||
+		strings.HasSuffix(local, "[bot]") ||
+		slices.Contains(botEmails, email)
+}
+
+// keep reports whether contacts with name and email make it into the
+// results: never without an email, as imported or malformed commits may
+// be, noreply addresses unless Options.IncludeNoreply, bots unless
+// Options.NoBots, then Options.Filter and Exclude.
+func (s *scanner) keep(name, email string) bool {
+	email = s.normalizeEmail(email)
+	switch {
+	case strings.TrimSpace(email) == "":
+		return false
+	case !s.opts.IncludeNoreply && isNoreply(email):
+		return false
+	case s.opts.NoBots && isBot(name, email):
+		return false
+	case s.opts.Filter != nil && !s.opts.Filter.MatchString(email):
+		return false
+	case s.opts.Exclude != nil && s.opts.Exclude.MatchString(email):
+		return false
+	}
+	return true
+}
+
+// parseNoreply splits a noreply address into the account id and login it
+// encodes: "12345678+octocat@..." or the older id-less "octocat@...".
+// Other addresses yield 0 and "".
+func parseNoreply(email string) (int64, string) {
+	if !isNoreply(email) {
+		return 0, ""
+	}
+	local, _, _ := strings.Cut(strings.TrimSpace(email), "@")
+	idPart, login, found := strings.Cut(local, "+")
+	if !found {
+		return 0, local
+	}
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		return 0, login
+	}
+	return id, login
+}
+
+// dedupKeys decide which contacts collapse into one during a merge.
+var dedupKeys = map[string]func(Contact) string{
+	"email":      func(c Contact) string { return c.Email },
+	"email+name": func(c Contact) string { return c.Email + "\x00" + c.Name },
+	"name":       nameKey,
+}
+
+// nameKey keys contacts by name. Nameless ones, like Bitbucket authors
+// without a name, would all be one under it, so they are keyed by email,
+// apart from any name.
+func nameKey(c Contact) string {
+	if c.Name == "" {
+		return "\x00" + c.Email
+	}
+	return c.Name
+}
+
+// DedupKeyNames lists the valid Options.DedupKey values.
+func DedupKeyNames() string {
+	names := make([]string, 0, len(dedupKeys))
+	for name := range dedupKeys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// mergeContacts appends contacts to data, folding any whose key was
+// already seen into the earlier contact. Counts and signed commits add
+// up, per repo too, the name used most often for a key wins, ties go to
+// the first, and the others become Aliases; repos are unioned and the
+// latest LastActive is kept. seen maps keys to their index in data.
+func mergeContacts(data []Contact, seen map[string]int, key func(Contact) string, contacts []Contact) []Contact {
+	for _, c := range contacts {
+		k := key(c)
+		i, exists := seen[k]
+		if !exists {
+			seen[k] = len(data)
+			c.names = maps.Clone(c.nameCounts())
+			c.setAliases()
+			c.RepoCommits = maps.Clone(c.repoCounts())
+			data = append(data, c)
+			continue
+		}
+
+		m := &data[i]
+		m.Count += c.Count
+		m.Signed += c.Signed
+		if c.LastActive.After(m.LastActive) {
+			m.LastActive = c.LastActive
+		}
+		for name, n := range c.nameCounts() {
+			m.names[name] += n
+			if m.Name == "" || m.names[name] > m.names[m.Name] {
+				m.Name = name
+			}
+		}
+		m.setAliases()
+		for repo, n := range c.repoCounts() {
+			m.RepoCommits[repo] += n
+		}
+		for _, repo := range c.Repos {
+			if !slices.Contains(m.Repos, repo) {
+				m.Repos = append(m.Repos, repo)
+			}
+		}
+	}
+	return data
+}
@@ -0,0 +1,167 @@
+package sniffer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// RateLimitError means GitHub refused a request because the rate limit
+// is used up until ResetAt. Secondary is set for the limits on bursts of
+// requests, which GitHub lifts after a wait of its choosing rather than
+// at the hourly reset.
+type RateLimitError struct {
+	ResetAt   time.Time
+	Secondary bool
+}
+
+func (e RateLimitError) Error() string {
+	if e.Secondary {
+		return fmt.Sprintf("secondary rate limit, retry at %s", e.ResetAt.Local().Format("15:04:05"))
+	}
+	return fmt.Sprintf("rate limited, resets at %s", e.ResetAt.Local().Format("15:04"))
+}
+
+// StatusError is an API response with an unexpected status code.
+type StatusError struct {
+	Path   string
+	Code   int
+	Status string
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Status)
+}
+
+// GraphQLError is an error GitHub reported in a GraphQL response, which
+// comes with a 200 status.
+type GraphQLError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+func (e GraphQLError) Error() string { return "graphql: " + e.Message }
+
+// AuthError means the token was rejected (401) or lacks access to what
+// was asked for (403).
+type AuthError struct {
+	StatusError
+}
+
+func (e AuthError) Error() string { return "not authorized: " + e.StatusError.Error() }
+func (e AuthError) Unwrap() error { return e.StatusError }
+
+// UserNotFoundError means there is neither a user nor an organization by
+// the name of User.
+type UserNotFoundError struct {
+	User string
+	Err  error
+}
+
+func (e UserNotFoundError) Error() string { return fmt.Sprintf("user %s not found", e.User) }
+func (e UserNotFoundError) Unwrap() error { return e.Err }
+
+// NetworkError means the API could not be reached, or the connection
+// broke or timed out before a response came back.
+type NetworkError struct {
+	Err error
+}
+
+func (e NetworkError) Error() string { return "network error: " + e.Err.Error() }
+func (e NetworkError) Unwrap() error { return e.Err }
+
+// DecodeError means a response didn't hold the JSON expected, like the
+// HTML error page of a proxy or a GitHub outage. Body is the start of
+// the response, for diagnostics.
+type DecodeError struct {
+	Path string
+	// Code is the status of the response, 0 when it came from the cache.
+	Code int
+	Body string
+	Err  error
+}
+
+func (e DecodeError) Error() string {
+	msg := "unexpected response from " + e.Path
+	if e.Code != 0 {
+		msg += fmt.Sprintf(" (status %d)", e.Code)
+	}
+	return fmt.Sprintf("%s: %v: %q", msg, e.Err, e.Body)
+}
+func (e DecodeError) Unwrap() error { return e.Err }
+
+// maxSnippet is how many bytes of a response body a DecodeError keeps.
+const maxSnippet = 200
+
+// snippet returns the start of body, whitespace collapsed and cut to
+// maxSnippet bytes on a rune boundary.
+func snippet(body []byte) string {
+	s := strings.Join(strings.Fields(string(body)), " ")
+	if len(s) <= maxSnippet {
+		return s
+	}
+	cut := maxSnippet
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "…"
+}
+
+// userNotFound turns a 404 from listing the repos of user into a
+// UserNotFoundError.
+func userNotFound(user string, err error) error {
+	var status StatusError
+	if errors.As(err, &status) && status.Code == http.StatusNotFound {
+		return UserNotFoundError{User: user, Err: err}
+	}
+	return err
+}
+
+// secondaryLimit tells whether the 403 or 429 res is a secondary rate
+// limit rather than a lack of access: a 429 always is, a 403 when it
+// says when to retry or GitHub's message says so. The body is left for
+// the caller to read.
+func secondaryLimit(res *http.Response) bool {
+	if res.StatusCode == http.StatusTooManyRequests || res.Header.Get("Retry-After") != "" {
+		return true
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	return err == nil && strings.Contains(strings.ToLower(string(body)), "secondary rate limit")
+}
+
+// checkResponse turns an unsuccessful API response into an error so its
+// body never reaches json.Unmarshal.
+func checkResponse(res *http.Response) error {
+	if res.StatusCode < 300 {
+		return nil
+	}
+
+	limited := res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusTooManyRequests
+	if limited && res.Header.Get("X-RateLimit-Remaining") == "0" {
+		reset, _ := strconv.ParseInt(res.Header.Get("X-RateLimit-Reset"), 10, 64)
+		return RateLimitError{ResetAt: time.Unix(reset, 0)}
+	}
+	if limited && secondaryLimit(res) {
+		// doWithRetry gave up on the wait, or ran out of retries.
+		wait, ok := retryAfter(res)
+		if !ok {
+			// GitHub asks for at least a minute when it doesn't say.
+			wait = time.Minute
+		}
+		return RateLimitError{ResetAt: time.Now().Add(wait), Secondary: true}
+	}
+
+	status := StatusError{Path: res.Request.URL.Path, Code: res.StatusCode, Status: res.Status}
+	if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+		return AuthError{status}
+	}
+	return status
+}
@@ -0,0 +1,204 @@
+package sniffer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// curl https://api.github.com/users/notTGY/gists
+
+type GistDataPiece struct {
+	ID         string `json:"id"`
+	GitPullURL string `json:"git_pull_url"`
+}
+
+// gistLogFormat has git log print the author and committer of a commit,
+// name, email and date each, NUL separated on a line of its own.
+const gistLogFormat = "%an%x00%ae%x00%aI%x00%cn%x00%ce%x00%cI"
+
+// collectGists reads the public gists of user, one worker per limiter
+// slot like the repos. The outcome of every gist comes back in listing
+// order, named gist:<id>, after the listing itself when it failed.
+func (s *scanner) collectGists(ctx context.Context, user string) []RepoError {
+	var gists []GistDataPiece
+	err := s.limited(ctx, func() (err error) {
+		gists, err = s.listGists(ctx, user)
+		return err
+	})
+	var results []RepoError
+	if err != nil {
+		// The gists listed before the failure are still read.
+		results = append(results, RepoError{Repo: "public gists", Err: err})
+	}
+
+	gistResults := make([]RepoError, len(gists))
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	for range cap(s.limiter) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				name := "gist:" + gists[i].ID
+				if err := s.acquire(ctx); err != nil {
+					gistResults[i] = RepoError{Repo: name, Err: err}
+					continue
+				}
+				contacts, err := s.getGistEmails(ctx, name, gists[i].GitPullURL)
+				s.release()
+				s.markPlaceholders(contacts)
+				gistResults[i] = RepoError{Repo: name, Contacts: contacts, Err: err}
+			}
+		}()
+	}
+	for i := range gists {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return append(results, gistResults...)
+}
+
+// listGists lists the public gists of user and the pages after the first.
+func (s *scanner) listGists(ctx context.Context, user string) ([]GistDataPiece, error) {
+	ctx, cancel := s.listingDeadline(ctx)
+	defer cancel()
+
+	data := []GistDataPiece{}
+	url := fmt.Sprintf("%s/%s/gists?per_page=100", s.baseUsers, user)
+	for url != "" {
+		var gistData []GistDataPiece
+		next, err := s.getJSON(ctx, url, &gistData)
+		if err != nil {
+			return data, err
+		}
+		data = append(data, gistData...)
+
+		url = next
+	}
+	return data, nil
+}
+
+// getGistEmails collects the contacts of the gist at url from the commits
+// made between Options.Since and Until. The API names only the account
+// behind each revision, so the gist is cloned, without its files, into a
+// temporary directory and its log read instead.
+func (s *scanner) getGistEmails(ctx context.Context, name, url string) ([]Contact, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+	if s.opts.Throttle != nil {
+		if err := s.opts.Throttle.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "github-sniffer-gist-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	// The URL comes from the API: after "--" it can't pass for an option.
+	clone := []string{"clone", "--bare", "--quiet", "--filter=blob:none", "--", url, dir}
+	if proxy, ok := s.gitProxy(url); ok {
+		// An empty http.proxy also keeps git off the environment's.
+		clone = append([]string{"-c", "http.proxy=" + proxy}, clone...)
+	}
+	if _, err := git(ctx, "", clone...); err != nil {
+		return nil, err
+	}
+
+	args := []string{"log", "--format=" + gistLogFormat}
+	if s.opts.MaxCommits > 0 {
+		// One more tells whether the cap left any out.
+		args = append(args, fmt.Sprintf("--max-count=%d", s.opts.MaxCommits+1))
+	}
+	if !s.opts.Since.IsZero() {
+		args = append(args, "--since="+s.opts.Since.UTC().Format(time.RFC3339))
+	}
+	if !s.opts.Until.IsZero() {
+		args = append(args, "--until="+s.opts.Until.UTC().Format(time.RFC3339))
+	}
+	if s.opts.FirstParent {
+		args = append(args, "--first-parent")
+	}
+	out, err := git(ctx, dir, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []Commit
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		if line == "" {
+			// Nothing was committed in range.
+			continue
+		}
+		f := strings.Split(line, "\x00")
+		if len(f) != 6 {
+			return nil, fmt.Errorf("unexpected git log line %q", line)
+		}
+		authored, _ := time.Parse(time.RFC3339, f[2])
+		committed, _ := time.Parse(time.RFC3339, f[5])
+		commits = append(commits, Commit{
+			Author:    Author{Name: f[0], Email: f[1], Date: authored},
+			Committer: Author{Name: f[3], Email: f[4], Date: committed},
+		})
+	}
+	if s.opts.MaxCommits > 0 && len(commits) > s.opts.MaxCommits {
+		s.cutShort(name)
+		commits = commits[:s.opts.MaxCommits]
+	}
+
+	data := []Contact{}
+	return mergeContacts(data, make(map[string]int), s.dedupKey, s.commitContacts(name, commits)), nil
+}
+
+// gitProxy returns the proxy Options.Client would send a request for url
+// through, "" for none, so git clones take the same way. ok is false when
+// the client's transport doesn't tell, and git picks its own.
+func (s *scanner) gitProxy(url string) (proxy string, ok bool) {
+	transport := s.client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	t, ok := transport.(*http.Transport)
+	if !ok {
+		return "", false
+	}
+	if t.Proxy == nil {
+		return "", true
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", false
+	}
+	u, err := t.Proxy(req)
+	if err != nil || u == nil {
+		return "", err == nil
+	}
+	return u.String(), true
+}
+
+// git runs git in dir and returns what it printed. A failure carries what
+// git said about it.
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	// Never wait for credentials nobody is there to type.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	out, err := cmd.Output()
+	if err != nil && ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		return "", fmt.Errorf("git: %s", strings.TrimSpace(string(exit.Stderr)))
+	}
+	return string(out), err
+}
@@ -0,0 +1,504 @@
+package sniffer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// curl https://api.github.com/users/notTGY/repos
+// curl https://api.github.com/repos/notTGY/mojango/commits
+
+type Author struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+type Commit struct {
+	Author    Author `json:"author"`
+	Committer Author `json:"committer"`
+	// Verification is how REST reports a GPG or SSH signature, Signature
+	// how GraphQL does.
+	Verification struct {
+		Verified bool `json:"verified"`
+	} `json:"verification"`
+	Signature *struct {
+		IsValid bool `json:"isValid"`
+	} `json:"signature"`
+}
+
+// signed reports whether c carries a signature GitHub verified.
+func (c Commit) signed() bool {
+	return c.Verification.Verified || (c.Signature != nil && c.Signature.IsValid)
+}
+
+type CommitDataPiece struct {
+	SHA     string `json:"sha"`
+	Commit  Commit `json:"commit"`
+	Parents []struct {
+		SHA string `json:"sha"`
+	} `json:"parents"`
+}
+
+type RepoDataPiece struct {
+	FullName string `json:"full_name"`
+	Fork     bool   `json:"fork"`
+}
+
+type ContributorDataPiece struct {
+	Login         string `json:"login"`
+	Type          string `json:"type"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	Contributions int    `json:"contributions"`
+}
+
+type EventDataPiece struct {
+	Type string `json:"type"`
+	Repo struct {
+		Name string `json:"name"`
+	} `json:"repo"`
+	Payload struct {
+		Commits []struct {
+			Author Author `json:"author"`
+		} `json:"commits"`
+	} `json:"payload"`
+}
+
+type UserDataPiece struct {
+	Login string `json:"login"`
+}
+
+// newRequest builds a GitHub API request carrying the headers every call
+// needs.
+func (s *scanner) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// GitHub answers requests without a User-Agent with 403.
+	req.Header.Set("User-Agent", s.opts.UserAgent)
+	if s.opts.Auth != "" {
+		// Bitbucket app passwords go with the username, as basic auth.
+		if user, password, ok := strings.Cut(s.opts.Auth, ":"); ok && s.opts.Provider == "bitbucket" {
+			req.SetBasicAuth(user, password)
+		} else {
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.opts.Auth))
+		}
+	}
+	return req, nil
+}
+
+// fetch returns the body of a successful response to url and the URL of
+// the next page, "" on the last one. Responses are served from and saved
+// to the cache when it is enabled.
+func (s *scanner) fetch(ctx context.Context, url string) ([]byte, string, error) {
+	cached, fresh, ok := s.readCache(url, s.cacheTTL(ctx))
+	if fresh {
+		s.counters.cacheHits.Add(1)
+		return cached.Body, cached.Next, nil
+	}
+
+	// Each page gets the whole Timeout, so a long history takes as many
+	// as it needs. The body is in memory before the deadline goes.
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+
+	req, err := s.newRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if ok && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	res, err := s.doWithRetry(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+	// The client follows the redirects of renamed users and repos.
+	if final := res.Request.URL.String(); final != url {
+		s.log.Info("redirected", "from", url, "to", final)
+	}
+	if res.StatusCode == http.StatusNotModified {
+		// Unchanged, and a 304 doesn't count against the rate limit.
+		s.counters.cacheHits.Add(1)
+		s.writeCache(url, cached)
+		return cached.Body, cached.Next, nil
+	}
+	if err := checkResponse(res); err != nil {
+		return nil, "", err
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(body) > 0 && !json.Valid(body) {
+		// Not worth caching either.
+		return nil, "", DecodeError{Path: res.Request.URL.Path, Code: res.StatusCode, Body: snippet(body), Err: errors.New("invalid JSON")}
+	}
+
+	if s.cacheDir != "" {
+		s.counters.cacheMisses.Add(1)
+	}
+	next := nextPage(res.Header)
+	s.writeCache(url, cacheEntry{Body: body, Next: next, ETag: res.Header.Get("ETag")})
+	return body, next, nil
+}
+
+// getJSON fetches url and decodes the JSON body into v. An empty body,
+// as GitHub sends for empty repos on some endpoints, leaves v untouched.
+// The URL of the next page is returned, "" on the last one.
+func (s *scanner) getJSON(ctx context.Context, url string, v any) (string, error) {
+	body, next, err := s.fetch(ctx, url)
+	if err != nil {
+		return "", err
+	}
+
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, v); err != nil {
+			return "", DecodeError{Path: urlPath(url), Body: snippet(body), Err: err}
+		}
+	}
+	return next, nil
+}
+
+// urlPath returns the path of url, for errors, or url itself when it
+// doesn't parse.
+func urlPath(url string) string {
+	if u, err := neturl.Parse(url); err == nil {
+		return u.Path
+	}
+	return url
+}
+
+// getLogin fetches a user object from url and returns its login.
+func (s *scanner) getLogin(ctx context.Context, url string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+
+	var userData UserDataPiece
+	if _, err := s.getJSON(ctx, url, &userData); err != nil {
+		return "", err
+	}
+	if userData.Login == "" {
+		return "", fmt.Errorf("could not resolve user from %s", url)
+	}
+
+	return userData.Login, nil
+}
+
+// getLoginByID resolves the current login of the account with id, which
+// survives renames unlike the login baked into old noreply addresses.
+func (s *scanner) getLoginByID(ctx context.Context, id int64) (string, error) {
+	return s.getLogin(ctx, fmt.Sprintf("%s/%d", s.baseUser, id))
+}
+
+// resolveLogins fills in Login for every contact with a noreply address.
+// A failed lookup keeps the login spelled in the address.
+func (s *scanner) resolveLogins(ctx context.Context, data []Contact) {
+	logins := make(map[int64]string)
+	for i := range data {
+		id, login := parseNoreply(data[i].Email)
+		data[i].Login = login
+		if id == 0 || !s.opts.ResolveNoreply {
+			continue
+		}
+		resolved, ok := logins[id]
+		if !ok {
+			var err error
+			err = s.limited(ctx, func() (err error) {
+				resolved, err = s.getLoginByID(ctx, id)
+				return err
+			})
+			if err != nil {
+				resolved = login
+			}
+			logins[id] = resolved
+		}
+		data[i].Login = resolved
+	}
+}
+
+// nextPage returns the rel="next" URL from a GitHub Link header, or ""
+// on the last page.
+func nextPage(h http.Header) string {
+	for _, link := range strings.Split(h.Get("Link"), ",") {
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 || strings.TrimSpace(parts[1]) != `rel="next"` {
+			continue
+		}
+		return strings.Trim(strings.TrimSpace(parts[0]), "<>")
+	}
+	return ""
+}
+
+func (s *scanner) getRepos(ctx context.Context, user string) ([]string, error) {
+	ctx, cancel := s.listingDeadline(ctx)
+	defer cancel()
+
+	own, err := s.ownAccount(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	orgURL := fmt.Sprintf("%s/%s/repos?per_page=100", s.baseOrgs, user)
+	switch {
+	case own:
+		// Only the authenticated endpoint lists private repos.
+		return s.listRepos(ctx, fmt.Sprintf("%s/repos?visibility=all&affiliation=owner&per_page=100", s.baseUser))
+	case s.opts.Org:
+		data, err := s.listRepos(ctx, orgURL)
+		return data, userNotFound(user, err)
+	}
+
+	data, err := s.listRepos(ctx, fmt.Sprintf("%s/%s/repos?per_page=100", s.baseUsers, user))
+	var status StatusError
+	if errors.As(err, &status) && status.Code == http.StatusNotFound {
+		// Not a user, maybe an organization.
+		data, err = s.listRepos(ctx, orgURL)
+	}
+	return data, userNotFound(user, err)
+}
+
+// ownAccount reports whether the private repos of user should be listed:
+// Options.IncludePrivate is set and user is the account Auth belongs to.
+func (s *scanner) ownAccount(ctx context.Context, user string) (bool, error) {
+	if !s.opts.IncludePrivate {
+		return false, nil
+	}
+	login, err := s.getLogin(ctx, s.baseUser)
+	if err != nil {
+		return false, err
+	}
+	if !strings.EqualFold(login, user) {
+		s.log.Warn("private repos are only listed for the token owner", "user", user, "owner", login)
+		return false, nil
+	}
+	return true, nil
+}
+
+// listRepos collects the full names of the repos listed at url and the
+// pages after it.
+func (s *scanner) listRepos(ctx context.Context, url string) ([]string, error) {
+	data := []string{}
+
+	for url != "" {
+		var repoData []RepoDataPiece
+		next, err := s.getJSON(ctx, url, &repoData)
+		if err != nil {
+			return data, err
+		}
+
+		for _, d := range repoData {
+			if (s.opts.NoForks && d.Fork) || s.excluded(d.FullName) {
+				continue
+			}
+			repo := d.FullName
+			data = append(data, repo)
+			if s.opts.MaxRepos > 0 && len(data) >= s.opts.MaxRepos {
+				return data, nil
+			}
+		}
+
+		url = next
+	}
+
+	return data, nil
+}
+
+// getRepoEmails collects the contacts of fullName from the commits made
+// between Options.Since and Until.
+func (s *scanner) getRepoEmails(ctx context.Context, fullName string) ([]Contact, error) {
+	const perPage = 100
+
+	data := []Contact{}
+	seen := make(map[string]int)
+	read := 0
+	url := fmt.Sprintf("%s/%s/commits?per_page=%d", s.baseRepos, fullName, perPage)
+	if !s.opts.Since.IsZero() {
+		url += "&since=" + s.opts.Since.UTC().Format(time.RFC3339)
+	}
+	if !s.opts.Until.IsZero() {
+		url += "&until=" + s.opts.Until.UTC().Format(time.RFC3339)
+	}
+	var line firstParentLine
+	for url != "" {
+		var commitData []CommitDataPiece
+		next, err := s.getJSON(ctx, url, &commitData)
+		var status StatusError
+		if errors.As(err, &status) && status.Code == http.StatusConflict {
+			// GitHub answers 409 for repos without any commits yet.
+			return data, nil
+		}
+		if err != nil {
+			return data, err
+		}
+		// The last page test below goes by what GitHub sent.
+		last := len(commitData) < perPage
+
+		if s.opts.FirstParent {
+			commitData = slices.DeleteFunc(commitData, func(d CommitDataPiece) bool {
+				parent := ""
+				if len(d.Parents) > 0 {
+					parent = d.Parents[0].SHA
+				}
+				return !line.on(d.SHA, parent)
+			})
+		}
+		capped := s.opts.MaxCommits > 0 && read+len(commitData) >= s.opts.MaxCommits
+		if capped && (read+len(commitData) > s.opts.MaxCommits || !(last || line.ended)) {
+			s.cutShort(fullName)
+		}
+		if s.opts.MaxCommits > 0 && read+len(commitData) > s.opts.MaxCommits {
+			commitData = commitData[:s.opts.MaxCommits-read]
+		}
+		read += len(commitData)
+
+		commits := make([]Commit, len(commitData))
+		for i, d := range commitData {
+			commits[i] = d.Commit
+		}
+		data = mergeContacts(data, seen, s.dedupKey, s.commitContacts(fullName, commits))
+
+		// A short page is the last one, no need to ask for another.
+		if last || line.ended || (s.opts.MaxCommits > 0 && read >= s.opts.MaxCommits) {
+			break
+		}
+		url = next
+	}
+
+	return data, nil
+}
+
+// firstParentLine picks the first-parent line out of a commit listing in
+// git log order, newest first: the first commit is on it, and then each
+// commit that is the first parent of the last one picked.
+type firstParentLine struct {
+	next    string
+	started bool
+	// ended is set past the root commit, when nothing more can be on it.
+	ended bool
+}
+
+// on tells whether the commit sha, with first parent parent ("" for a
+// root commit), is on the line.
+func (l *firstParentLine) on(sha, parent string) bool {
+	if l.ended || (l.started && sha != l.next) {
+		return false
+	}
+	l.started = true
+	l.next = parent
+	l.ended = parent == ""
+	return true
+}
+
+// commitContacts returns the identities of commits made in fullName, one
+// contact per identity and commit.
+func (s *scanner) commitContacts(fullName string, commits []Commit) []Contact {
+	contacts := make([]Contact, 0, 2*len(commits))
+	for _, commit := range commits {
+		identities := []Author{commit.Author}
+		if !s.opts.AuthorsOnly {
+			identities = append(identities, commit.Committer)
+		}
+		keys := make(map[string]struct{}, len(identities))
+		for _, a := range identities {
+			if !s.keep(a.Name, a.Email) {
+				continue
+			}
+			c := Contact{Name: a.Name, Email: s.normalizeEmail(a.Email), Repos: []string{fullName}, Count: 1, LastActive: a.Date}
+			if commit.signed() {
+				c.Signed = 1
+			}
+			// Authoring and committing the same commit counts once.
+			if _, dup := keys[s.dedupKey(c)]; dup {
+				continue
+			}
+			keys[s.dedupKey(c)] = struct{}{}
+			contacts = append(contacts, c)
+		}
+	}
+	return contacts
+}
+
+// getRepoContributors collects contacts from the contributor summary of
+// fullName: one request per 100 contributors instead of per 100 commits.
+// With anon=true the listing includes the commit identities that match
+// no account, as type "Anonymous" with their name and email. Registered
+// users are listed by login alone and so contribute nothing here.
+func (s *scanner) getRepoContributors(ctx context.Context, fullName string) ([]Contact, error) {
+	data := []Contact{}
+	seen := make(map[string]int)
+	url := fmt.Sprintf("%s/%s/contributors?anon=true&per_page=100", s.baseRepos, fullName)
+	for url != "" {
+		var contributorData []ContributorDataPiece
+		next, err := s.getJSON(ctx, url, &contributorData)
+		if err != nil {
+			return data, err
+		}
+
+		contacts := make([]Contact, 0, len(contributorData))
+		for _, d := range contributorData {
+			if d.Type != "Anonymous" || !s.keep(d.Name, d.Email) {
+				continue
+			}
+			contacts = append(contacts, Contact{
+				Name:  d.Name,
+				Email: s.normalizeEmail(d.Email),
+				Repos: []string{fullName},
+				Count: d.Contributions,
+			})
+		}
+		data = mergeContacts(data, seen, s.dedupKey, contacts)
+
+		url = next
+	}
+
+	return data, nil
+}
+
+// getEventEmails collects the commit authors of the pushes in the public
+// activity of user. GitHub keeps only the last 300 events.
+func (s *scanner) getEventEmails(ctx context.Context, user string) ([]Contact, error) {
+	ctx, cancel := s.listingDeadline(ctx)
+	defer cancel()
+
+	data := []Contact{}
+	seen := make(map[string]int)
+	url := fmt.Sprintf("%s/%s/events/public?per_page=100", s.baseUsers, user)
+	for url != "" {
+		var eventData []EventDataPiece
+		next, err := s.getJSON(ctx, url, &eventData)
+		if err != nil {
+			return data, err
+		}
+
+		contacts := []Contact{}
+		for _, e := range eventData {
+			if e.Type != "PushEvent" {
+				continue
+			}
+			for _, commit := range e.Payload.Commits {
+				a := commit.Author
+				if !s.keep(a.Name, a.Email) {
+					continue
+				}
+				contacts = append(contacts, Contact{Name: a.Name, Email: s.normalizeEmail(a.Email), Repos: []string{e.Repo.Name}, Count: 1})
+			}
+		}
+		data = mergeContacts(data, seen, s.dedupKey, contacts)
+
+		url = next
+	}
+
+	return data, nil
+}
@@ -0,0 +1,180 @@
+package sniffer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"time"
+)
+
+// DefaultGitLabURL is the API root of gitlab.com.
+const DefaultGitLabURL = "https://gitlab.com/api/v4"
+
+// curl https://gitlab.com/api/v4/users/gitlab-org/projects
+// curl https://gitlab.com/api/v4/projects/gitlab-org%2Fgitlab/repository/commits
+
+type GitLabCommitDataPiece struct {
+	AuthorName     string    `json:"author_name"`
+	AuthorEmail    string    `json:"author_email"`
+	AuthoredDate   time.Time `json:"authored_date"`
+	CommitterName  string    `json:"committer_name"`
+	CommitterEmail string    `json:"committer_email"`
+	CommittedDate  time.Time `json:"committed_date"`
+}
+
+type GitLabProjectDataPiece struct {
+	PathWithNamespace string `json:"path_with_namespace"`
+	// ForkedFromProject is only present on forks.
+	ForkedFromProject *struct{} `json:"forked_from_project"`
+}
+
+type GitLabContributorDataPiece struct {
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Commits int    `json:"commits"`
+}
+
+// gitlab fetches from a GitLab instance; repos are projects there, named
+// by their full path.
+type gitlab struct {
+	*scanner
+}
+
+// projectURL returns the URL of the project at path below the API root.
+func (g gitlab) projectURL(path string) string {
+	return fmt.Sprintf("%s/projects/%s", g.baseAPI, neturl.PathEscape(path))
+}
+
+func (g gitlab) getRepos(ctx context.Context, user string) ([]string, error) {
+	ctx, cancel := g.listingDeadline(ctx)
+	defer cancel()
+
+	groupURL := fmt.Sprintf("%s/groups/%s/projects?include_subgroups=true&per_page=100", g.baseAPI, neturl.PathEscape(user))
+	if g.opts.Org {
+		data, err := g.listProjects(ctx, groupURL)
+		return data, userNotFound(user, err)
+	}
+	data, err := g.listProjects(ctx, fmt.Sprintf("%s/users/%s/projects?per_page=100", g.baseAPI, neturl.PathEscape(user)))
+	var status StatusError
+	if errors.As(err, &status) && status.Code == http.StatusNotFound {
+		// Not a user, maybe a group.
+		data, err = g.listProjects(ctx, groupURL)
+	}
+	return data, userNotFound(user, err)
+}
+
+// listProjects collects the full paths of the projects listed at url and
+// the pages after it.
+func (g gitlab) listProjects(ctx context.Context, url string) ([]string, error) {
+	data := []string{}
+
+	for url != "" {
+		var projectData []GitLabProjectDataPiece
+		next, err := g.getJSON(ctx, url, &projectData)
+		if err != nil {
+			return data, err
+		}
+
+		for _, d := range projectData {
+			if (g.opts.NoForks && d.ForkedFromProject != nil) || g.excluded(d.PathWithNamespace) {
+				continue
+			}
+			data = append(data, d.PathWithNamespace)
+			if g.opts.MaxRepos > 0 && len(data) >= g.opts.MaxRepos {
+				return data, nil
+			}
+		}
+
+		url = next
+	}
+
+	return data, nil
+}
+
+// getRepoEmails collects the contacts of the project at path from the
+// commits of its default branch made between Options.Since and Until.
+func (g gitlab) getRepoEmails(ctx context.Context, path string) ([]Contact, error) {
+	const perPage = 100
+
+	data := []Contact{}
+	seen := make(map[string]int)
+	read := 0
+	url := fmt.Sprintf("%s/repository/commits?per_page=%d", g.projectURL(path), perPage)
+	if !g.opts.Since.IsZero() {
+		url += "&since=" + g.opts.Since.UTC().Format(time.RFC3339)
+	}
+	if !g.opts.Until.IsZero() {
+		url += "&until=" + g.opts.Until.UTC().Format(time.RFC3339)
+	}
+	if g.opts.FirstParent {
+		url += "&first_parent=true"
+	}
+	for url != "" {
+		var commitData []GitLabCommitDataPiece
+		next, err := g.getJSON(ctx, url, &commitData)
+		if err != nil {
+			return data, err
+		}
+
+		capped := g.opts.MaxCommits > 0 && read+len(commitData) >= g.opts.MaxCommits
+		if capped && (read+len(commitData) > g.opts.MaxCommits || len(commitData) == perPage) {
+			g.cutShort(path)
+		}
+		if g.opts.MaxCommits > 0 && read+len(commitData) > g.opts.MaxCommits {
+			commitData = commitData[:g.opts.MaxCommits-read]
+		}
+		read += len(commitData)
+
+		commits := make([]Commit, len(commitData))
+		for i, d := range commitData {
+			commits[i] = Commit{
+				Author:    Author{Name: d.AuthorName, Email: d.AuthorEmail, Date: d.AuthoredDate},
+				Committer: Author{Name: d.CommitterName, Email: d.CommitterEmail, Date: d.CommittedDate},
+			}
+		}
+		data = mergeContacts(data, seen, g.dedupKey, g.commitContacts(path, commits))
+
+		if len(commitData) < perPage || (g.opts.MaxCommits > 0 && read >= g.opts.MaxCommits) {
+			break
+		}
+		url = next
+	}
+
+	return data, nil
+}
+
+// getRepoContributors collects contacts from the contributor summary of
+// the project at path. Unlike GitHub's, it names every contributor by
+// email.
+func (g gitlab) getRepoContributors(ctx context.Context, path string) ([]Contact, error) {
+	data := []Contact{}
+	seen := make(map[string]int)
+	url := fmt.Sprintf("%s/repository/contributors?per_page=100", g.projectURL(path))
+	for url != "" {
+		var contributorData []GitLabContributorDataPiece
+		next, err := g.getJSON(ctx, url, &contributorData)
+		if err != nil {
+			return data, err
+		}
+
+		contacts := make([]Contact, 0, len(contributorData))
+		for _, d := range contributorData {
+			if !g.keep(d.Name, d.Email) {
+				continue
+			}
+			contacts = append(contacts, Contact{
+				Name:  d.Name,
+				Email: g.normalizeEmail(d.Email),
+				Repos: []string{path},
+				Count: d.Commits,
+			})
+		}
+		data = mergeContacts(data, seen, g.dedupKey, contacts)
+
+		url = next
+	}
+
+	return data, nil
+}
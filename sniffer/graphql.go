@@ -0,0 +1,268 @@
+package sniffer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// reposPerQuery keeps each repo page query, which carries the first
+// commits of every repo, well below GitHub's node and time limits.
+const reposPerQuery = 20
+
+type gqlPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type gqlHistory struct {
+	Nodes    []Commit    `json:"nodes"`
+	PageInfo gqlPageInfo `json:"pageInfo"`
+}
+
+// gqlBranchRef is a default branch, null for empty repos.
+type gqlBranchRef struct {
+	Target struct {
+		History gqlHistory `json:"history"`
+	} `json:"target"`
+}
+
+type gqlRepo struct {
+	NameWithOwner    string        `json:"nameWithOwner"`
+	DefaultBranchRef *gqlBranchRef `json:"defaultBranchRef"`
+}
+
+type gqlRepoPage struct {
+	Owner *struct {
+		Repositories struct {
+			TotalCount int         `json:"totalCount"`
+			Nodes      []gqlRepo   `json:"nodes"`
+			PageInfo   gqlPageInfo `json:"pageInfo"`
+		} `json:"repositories"`
+	} `json:"owner"`
+}
+
+type gqlHistoryPage struct {
+	Repository *struct {
+		DefaultBranchRef *gqlBranchRef `json:"defaultBranchRef"`
+	} `json:"repository"`
+}
+
+// postGraphQL runs query with vars and decodes its data into v. GraphQL
+// requests are POSTs and so bypass the response cache.
+func (s *scanner) postGraphQL(ctx context.Context, query string, vars map[string]any, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+
+	b, err := json.Marshal(map[string]any{"query": query, "variables": vars})
+	if err != nil {
+		return err
+	}
+	req, err := s.newRequest(ctx, "POST", s.baseGraphQL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.doWithRetry(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err := checkResponse(res); err != nil {
+		return err
+	}
+
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	var body struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []GraphQLError  `json:"errors"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return DecodeError{Path: res.Request.URL.Path, Code: res.StatusCode, Body: snippet(raw), Err: err}
+	}
+	if len(body.Errors) > 0 {
+		if body.Errors[0].Type == "RATE_LIMITED" {
+			return RateLimitError{ResetAt: s.budget.get().Reset}
+		}
+		return body.Errors[0]
+	}
+	return json.Unmarshal(body.Data, v)
+}
+
+// historyField selects the commits of the default branch, honoring
+// Options.Since and Until, pageSize at a time starting after $after.
+func (s *scanner) historyField(pageSize int, after string) string {
+	args := []string{fmt.Sprintf("first: %d", pageSize)}
+	if after != "" {
+		args = append(args, "after: "+after)
+	}
+	if !s.opts.Since.IsZero() {
+		args = append(args, fmt.Sprintf("since: %q", s.opts.Since.UTC().Format(time.RFC3339)))
+	}
+	if !s.opts.Until.IsZero() {
+		args = append(args, fmt.Sprintf("until: %q", s.opts.Until.UTC().Format(time.RFC3339)))
+	}
+	return `defaultBranchRef { target { ... on Commit {
+		history(` + strings.Join(args, ", ") + `) {
+			nodes { author { name email date } committer { name email date } signature { isValid } }
+			pageInfo { hasNextPage endCursor }
+		}
+	} } }`
+}
+
+// commitPageSize is how many commits are asked for at once.
+func (s *scanner) commitPageSize() int {
+	if s.opts.MaxCommits > 0 {
+		return min(s.opts.MaxCommits, 100)
+	}
+	return 100
+}
+
+// collectGraphQL does what collectREST does in far fewer requests: every
+// page of repos arrives with the first commits of each, only repos with a
+// longer history need more queries.
+func (s *scanner) collectGraphQL(ctx context.Context, user string) ([]string, map[string]RepoError, error) {
+	var own bool
+	err := s.limited(ctx, func() (err error) {
+		own, err = s.ownAccount(ctx, user)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	// Same selection as the REST listing: owned, public unless it is the
+	// token's own account, sorted by name.
+	args := "first: $first, after: $after, ownerAffiliations: OWNER, orderBy: {field: NAME, direction: ASC}"
+	if !own {
+		args += ", privacy: PUBLIC"
+	}
+	if s.opts.NoForks {
+		args += ", isFork: false"
+	}
+	owner := "repositoryOwner(login: $login)"
+	if own {
+		owner = "viewer"
+	}
+	query := `query($login: String!, $first: Int!, $after: String) {
+	owner: ` + owner + ` {
+		repositories(` + args + `) {
+			totalCount
+			nodes { nameWithOwner ` + s.historyField(s.commitPageSize(), "") + ` }
+			pageInfo { hasNextPage endCursor }
+		}
+	}
+}`
+	if own {
+		// viewer takes no login, and GraphQL rejects unused variables.
+		query = strings.Replace(query, "$login: String!, ", "", 1)
+	}
+
+	repos := []string{}
+	results := make(map[string]RepoError)
+	vars := map[string]any{"first": reposPerQuery, "after": nil}
+	if !own {
+		vars["login"] = user
+	}
+	for {
+		var page gqlRepoPage
+		if err := s.acquire(ctx); err != nil {
+			return nil, nil, err
+		}
+		err := s.postGraphQL(ctx, query, vars, &page)
+		s.release()
+		if err != nil {
+			return nil, nil, err
+		}
+		if page.Owner == nil {
+			return nil, nil, UserNotFoundError{User: user}
+		}
+
+		conn := page.Owner.Repositories
+		total := conn.TotalCount
+		if s.opts.MaxRepos > 0 {
+			total = min(total, s.opts.MaxRepos)
+		}
+		s.progress(len(repos), total)
+		for _, repo := range conn.Nodes {
+			if s.excluded(repo.NameWithOwner) {
+				continue
+			}
+			contacts, err := s.repoHistory(ctx, repo)
+			s.markPlaceholders(contacts)
+			r := RepoError{Repo: repo.NameWithOwner, Contacts: contacts, Err: err}
+			s.repoDone(r)
+			repos = append(repos, repo.NameWithOwner)
+			results[repo.NameWithOwner] = r
+			s.progress(len(repos), total)
+			if s.opts.MaxRepos > 0 && len(repos) >= s.opts.MaxRepos {
+				return repos, results, nil
+			}
+		}
+
+		if !conn.PageInfo.HasNextPage {
+			return repos, results, nil
+		}
+		vars["after"] = conn.PageInfo.EndCursor
+	}
+}
+
+// repoHistory collects the contacts of repo from the commits that came
+// with the repo page and the ones after them, up to Options.MaxCommits.
+func (s *scanner) repoHistory(ctx context.Context, repo gqlRepo) ([]Contact, error) {
+	data := []Contact{}
+	if repo.DefaultBranchRef == nil {
+		// No commits yet.
+		return data, nil
+	}
+
+	owner, name, _ := strings.Cut(repo.NameWithOwner, "/")
+	query := `query($owner: String!, $name: String!, $after: String) {
+	repository(owner: $owner, name: $name) { ` + s.historyField(s.commitPageSize(), "$after") + ` }
+}`
+
+	seen := make(map[string]int)
+	read := 0
+	history := repo.DefaultBranchRef.Target.History
+	for {
+		commits := history.Nodes
+		capped := s.opts.MaxCommits > 0 && read+len(commits) >= s.opts.MaxCommits
+		if capped && (read+len(commits) > s.opts.MaxCommits || history.PageInfo.HasNextPage) {
+			s.cutShort(repo.NameWithOwner)
+		}
+		if s.opts.MaxCommits > 0 && read+len(commits) > s.opts.MaxCommits {
+			commits = commits[:s.opts.MaxCommits-read]
+		}
+		read += len(commits)
+		data = mergeContacts(data, seen, s.dedupKey, s.commitContacts(repo.NameWithOwner, commits))
+
+		if !history.PageInfo.HasNextPage || (s.opts.MaxCommits > 0 && read >= s.opts.MaxCommits) {
+			return data, nil
+		}
+
+		var page gqlHistoryPage
+		if err := s.acquire(ctx); err != nil {
+			return data, err
+		}
+		err := s.postGraphQL(ctx, query, map[string]any{
+			"owner": owner,
+			"name":  name,
+			"after": history.PageInfo.EndCursor,
+		}, &page)
+		s.release()
+		if err != nil {
+			return data, err
+		}
+		if page.Repository == nil || page.Repository.DefaultBranchRef == nil {
+			return data, nil
+		}
+		history = page.Repository.DefaultBranchRef.Target.History
+	}
+}
@@ -0,0 +1,44 @@
+package sniffer
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+// placeholders are glob patterns (see path.Match) for the addresses git
+// falls back to when user.email was never configured, plus the usual
+// documentation examples. Matching emails are flagged, not dropped.
+var placeholders = []string{
+	"*@localhost",
+	"*@localhost.*",
+	"*.localdomain",
+	"*@*.local",
+	"*(none)*",
+	"git@*",
+	"root@*",
+	"you@*",
+	"user@*",
+	"*@example.com",
+	"*@example.org",
+	"*@example.net",
+}
+
+// isPlaceholder tells whether email matches one of placeholders or
+// Options.Placeholders.
+func (s *scanner) isPlaceholder(email string) bool {
+	email = strings.ToLower(strings.TrimSpace(email))
+	for _, p := range slices.Concat(placeholders, s.opts.Placeholders) {
+		if ok, _ := path.Match(strings.ToLower(p), email); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// markPlaceholders flags the placeholder addresses among contacts.
+func (s *scanner) markPlaceholders(contacts []Contact) {
+	for i := range contacts {
+		contacts[i].Placeholder = s.isPlaceholder(contacts[i].Email)
+	}
+}
@@ -0,0 +1,155 @@
+package sniffer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// doWithRetry sends req with the scanner's client, retrying up to
+// MaxRetries times when GitHub asks to slow down with Retry-After
+// (secondary rate limits), fails with a 5xx, or the connection breaks
+// before the whole body arrived. The body is read here for that, so the
+// response returned holds it in memory. The last response is returned as
+// is.
+func (s *scanner) doWithRetry(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if s.opts.Throttle != nil {
+			if err := s.opts.Throttle.Wait(req.Context()); err != nil {
+				return nil, err
+			}
+		}
+		s.counters.requests.Add(1)
+		res, err := s.client.Do(req)
+		if err == nil {
+			s.recordBudget(res.Header)
+			if res.StatusCode == http.StatusNotModified {
+				s.counters.notModified.Add(1)
+			} else {
+				s.counters.rateLimitUsed.Add(1)
+			}
+			err = bufferBody(res)
+		}
+		if err != nil && (req.Context().Err() == nil || errors.Is(err, context.DeadlineExceeded)) {
+			// A timeout is as much a network problem as a refused
+			// connection; only a cancelled scan isn't.
+			err = NetworkError{Err: err}
+		}
+		if err != nil && (attempt >= s.opts.MaxRetries || !retryable(req, err)) {
+			return nil, err
+		}
+		if err == nil && attempt >= s.opts.MaxRetries {
+			return res, nil
+		}
+
+		var wait time.Duration
+		if err != nil {
+			wait = backoff(attempt)
+		} else {
+			var retry bool
+			if wait, retry = retryDelay(res, attempt); !retry {
+				return res, nil
+			}
+		}
+		// Don't start a wait the request deadline won't survive.
+		if deadline, ok := req.Context().Deadline(); ok && time.Until(deadline) < wait {
+			if err != nil {
+				return nil, err
+			}
+			return res, nil
+		}
+		if err != nil {
+			s.log.Info("retrying", "url", req.URL.String(), "err", err, "wait", wait)
+		} else {
+			res.Body.Close()
+			s.log.Info("retrying", "url", req.URL.String(), "status", res.StatusCode, "wait", wait)
+		}
+		s.counters.retries.Add(1)
+
+		t := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			t.Stop()
+			return nil, req.Context().Err()
+		case <-t.C:
+		}
+		// The first attempt used up the body of a POST.
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// retryDelay says whether res is worth retrying and after how long.
+func retryDelay(res *http.Response, attempt int) (time.Duration, bool) {
+	switch {
+	case res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusTooManyRequests:
+		return retryAfter(res)
+	case res.StatusCode >= 500:
+		return backoff(attempt), true
+	}
+	return 0, false
+}
+
+// retryAfter returns the wait the Retry-After header of res asks for, in
+// seconds or as a date; false without a valid one.
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	after := res.Header.Get("Retry-After")
+	if after == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(after); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(after); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// backoff is the wait before retry number attempt+1: exponential,
+// jittered so workers spread out.
+func backoff(attempt int) time.Duration {
+	d := 500 * time.Millisecond << attempt
+	return d/2 + rand.N(d/2+1)
+}
+
+// bufferBody reads the body of res into memory, so a connection dropped
+// halfway through fails the request where it can still be retried.
+func bufferBody(res *http.Response) error {
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	return nil
+}
+
+// retryable tells whether the network error err of req is likely to pass
+// if the request is sent again: a connection reset or cut short, or a
+// timeout with time left on the request. Unknown hosts, refused
+// connections and TLS failures won't fix themselves.
+func retryable(req *http.Request, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF),
+		errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNABORTED), errors.Is(err, syscall.EPIPE):
+		return true
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	}
+	return false
+}
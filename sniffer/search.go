@@ -0,0 +1,82 @@
+package sniffer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"time"
+)
+
+// maxSearchWait caps how long a search waits for its rate limit to
+// reset. The search limit resets every minute, so a longer wait means a
+// wrong clock rather than a busy API.
+const maxSearchWait = time.Minute
+
+type searchUsersPage struct {
+	TotalCount int `json:"total_count"`
+	Items      []struct {
+		Login string `json:"login"`
+	} `json:"items"`
+}
+
+// searchLogins fills in Login for the contacts that have none by
+// searching the accounts with their address as public email. The search
+// API has its own, much lower rate limit: a search that hits it waits
+// for the reset, up to MaxRetries times, and the first failure ends the
+// lookups.
+func (s *scanner) searchLogins(ctx context.Context, data []Contact) {
+	for i := range data {
+		if data[i].Login != "" {
+			continue
+		}
+		var login string
+		err := s.limited(ctx, func() (err error) {
+			login, err = s.searchLogin(ctx, data[i].Email)
+			return err
+		})
+		if err != nil {
+			s.log.Warn("login search stopped", "email", data[i].Email, "err", err)
+			return
+		}
+		data[i].Login = login
+	}
+}
+
+// searchLogin returns the only account with email as public address, ""
+// when none or several match.
+func (s *scanner) searchLogin(ctx context.Context, email string) (string, error) {
+	url := fmt.Sprintf("%s/search/users?q=%s", s.baseAPI, neturl.QueryEscape(email+" in:email"))
+	for attempt := 0; ; attempt++ {
+		var page searchUsersPage
+		err := s.getSearch(ctx, url, &page)
+		var rateLimit RateLimitError
+		if errors.As(err, &rateLimit) && attempt < s.opts.MaxRetries {
+			wait := min(max(time.Until(rateLimit.ResetAt), time.Second), maxSearchWait)
+			s.log.Info("search rate limited", "wait", wait)
+			t := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return "", ctx.Err()
+			case <-t.C:
+			}
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if page.TotalCount != 1 || len(page.Items) != 1 {
+			return "", nil
+		}
+		return page.Items[0].Login, nil
+	}
+}
+
+// getSearch fetches one search url into v within Options.Timeout.
+func (s *scanner) getSearch(ctx context.Context, url string, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+	_, err := s.getJSON(ctx, url, v)
+	return err
+}
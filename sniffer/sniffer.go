@@ -0,0 +1,737 @@
+// Package sniffer collects the email addresses a GitHub user or
+// organization committed under, from the commit metadata of their repos.
+// GitLab users and groups and Bitbucket workspaces work the same way.
+package sniffer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	neturl "net/url"
+	"os/exec"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// DefaultBaseURL is the API root of github.com.
+const DefaultBaseURL = "https://api.github.com"
+
+// Options configure a scan. The zero value scans public repos
+// anonymously with the defaults noted on each field.
+type Options struct {
+	// Provider is the forge scanned, "github", the default, "gitlab" or
+	// "bitbucket".
+	Provider string
+	// Auth is a token sent as a Bearer token, "" for anonymous requests.
+	// On Bitbucket "username:app-password" is sent as basic auth.
+	Auth string
+	// BaseURL is the API root, DefaultBaseURL, DefaultGitLabURL or
+	// DefaultBitbucketURL when empty. A bare GitHub Enterprise Server host
+	// gets its /api/v3 prefix added, a bare GitLab host /api/v4 and a bare
+	// Bitbucket host /2.0.
+	BaseURL string
+	// UserAgent is sent with every request, "github-sniffer" when empty.
+	UserAgent string
+	// Client sends the requests, http.DefaultClient when nil.
+	Client *http.Client
+	// Timeout bounds every request, and repo listings as a whole, all
+	// their pages included; 10 seconds when zero. Reading the commits of
+	// a repo takes as many requests as it needs.
+	Timeout time.Duration
+	// MaxRetries caps the retries of rate limited (Retry-After) and 5xx
+	// responses.
+	MaxRetries int
+	// CacheDir enables the on-disk response cache kept there, "" disables
+	// it. Cached responses are revalidated with their ETag, which costs no
+	// rate limit when they are unchanged.
+	CacheDir string
+	// CacheTTL is how long cached responses are used without asking
+	// GitHub at all.
+	CacheTTL time.Duration
+	// ReposCacheTTL is CacheTTL for the repo listings of users, which
+	// change far less often than commits; 0 uses CacheTTL.
+	ReposCacheTTL time.Duration
+
+	// Concurrency is the number of repos fetched at the same time, 8 when
+	// zero; the repo listing and the events and login lookups of a scan
+	// take a turn like a repo. It is ignored when Limiter is set.
+	Concurrency int
+	// Limiter, if set, is shared by every Sniff call using it so the
+	// limit holds across all of them.
+	Limiter Limiter
+	// Throttle, if set, paces every request, retries included, however
+	// many run at once. Like Limiter it may be shared by several Sniff
+	// calls.
+	Throttle *rate.Limiter
+
+	// Org treats the user as an organization without trying the user
+	// endpoint first.
+	Org bool
+	// IncludePrivate also lists the private repos of the user when it is
+	// the account Auth belongs to; other users get their public repos. On
+	// Bitbucket it lists every private repo of the workspace Auth can
+	// see. It needs Auth.
+	IncludePrivate bool
+	// Repos, if not empty, are the full names of the repos to scan instead
+	// of all repos of the user.
+	Repos []string
+	// NoForks skips forked repos.
+	NoForks bool
+	// ExcludeRepos are glob patterns, as in path.Match, of repos to skip
+	// when listing. A pattern with a slash is matched against the full
+	// name, owner/name, one without against the name alone. Repos named
+	// in Repos are scanned regardless.
+	ExcludeRepos []string
+	// MaxRepos and MaxCommits stop listing repos and reading the commits
+	// of a repo after that many, 0 for no limit.
+	MaxRepos   int
+	MaxCommits int
+	// Sample, if positive, scans only that many of the listed repos,
+	// picked at random, for a quick estimate of a big account. SampleSeed
+	// makes the pick reproducible; 0 picks another sample every time.
+	// Repos named in Repos are all scanned, and GraphQL falls back to
+	// REST with it.
+	Sample     int
+	SampleSeed uint64
+
+	// Source is where emails come from: "commits", the default, walks the
+	// history of every repo, "contributors" reads the contributor summary.
+	Source string
+	// Since and Until limit the commits read; a zero time leaves that end
+	// open. Only supported with the commits source.
+	Since, Until time.Time
+	// FirstParent only reads the commits on the first-parent line of the
+	// default branch, as git log --first-parent does: merges stay, the
+	// commits of the branches they merged are left out. GitHub has no
+	// such filter, so the full listing is read and walked here. Not
+	// supported on Bitbucket, and GraphQL falls back to REST with it.
+	FirstParent bool
+	// IncludeEvents also collects the commit authors of the user's public
+	// push events.
+	IncludeEvents bool
+	// IncludeGists also collects the commit identities of the user's
+	// public gists, whatever the Source. The API doesn't show them, so
+	// every gist is cloned with git, which must be on the PATH. The clones
+	// go through the proxy of Client when its transport is an
+	// *http.Transport, and are not counted in Result.Stats.
+	IncludeGists bool
+
+	// AuthorsOnly ignores committer identities.
+	AuthorsOnly bool
+	// IncludeNoreply keeps users.noreply.github.com addresses.
+	IncludeNoreply bool
+	// NoBots drops identities that look like automation, such as
+	// dependabot[bot] or actions@github.com.
+	NoBots bool
+	// Filter, if set, keeps only the addresses it matches; Exclude drops
+	// the ones it matches. Both see addresses after normalization.
+	Filter, Exclude *regexp.Regexp
+	// Placeholders are glob patterns, as in path.Match, of more addresses
+	// to flag as Contact.Placeholder besides git's fallbacks and the
+	// documentation examples. Case doesn't matter.
+	Placeholders []string
+	// CaseSensitive keeps addresses as committed instead of trimming and
+	// lowercasing them before they are deduplicated.
+	CaseSensitive bool
+	// GraphQL gathers the commits through the GraphQL API, a handful of
+	// requests for many repos. It needs Auth and the commits source, and
+	// falls back to REST otherwise.
+	GraphQL bool
+	// MinCommits drops the contacts with fewer commits than this across
+	// all repos, 0 keeps everyone.
+	MinCommits int
+	// ResolveNoreply looks up the current login behind numeric noreply
+	// addresses.
+	ResolveNoreply bool
+	// ResolveLogins searches the account of every other address among
+	// the users with it as public email. Searches have a rate limit of
+	// their own, a few dozen a minute, so this is slow on big scans.
+	ResolveLogins bool
+	// DedupKey decides how identities collapse, one of DedupKeyNames;
+	// "email" when empty.
+	DedupKey string
+
+	// Progress, if not nil, is called after the repos are listed and each
+	// time one is done.
+	Progress func(done, total int)
+	// RepoStart, if not nil, is called with the full name of every repo
+	// as its reading starts. GraphQL reads repos in batches and doesn't
+	// call it. Like RepoDone it may be called from several goroutines.
+	RepoStart func(repo string)
+	// BudgetChanged, if not nil, gets the rate limit budget of the scan
+	// each time a response changes it. Like RepoDone it may be called
+	// from several goroutines at once.
+	BudgetChanged func(Budget)
+	// RepoDone, if not nil, gets the outcome of every repo as it finishes,
+	// Err set when it failed. It may be called from several goroutines at
+	// once.
+	RepoDone func(RepoError)
+	// Logger gets retried requests at info level and other trouble at
+	// warn level; nil discards them.
+	Logger *slog.Logger
+}
+
+// Validate reports options Sniff would reject.
+func (o Options) Validate() error {
+	_, err := newScanner(o)
+	return err
+}
+
+// Limiter bounds the number of repos fetched at once.
+type Limiter chan struct{}
+
+// NewLimiter returns a Limiter letting n fetches run at the same time.
+func NewLimiter(n int) Limiter {
+	return make(Limiter, n)
+}
+
+// Result is the outcome of a finished scan.
+type Result struct {
+	User string
+	// RenamedTo is the current name of User when GitHub redirected the
+	// old one, "" otherwise.
+	RenamedTo string
+	Contacts  []Contact
+	// Failed lists the repos that could not be scanned completely.
+	Failed []RepoError
+	// Stats is what the scan cost in requests.
+	Stats Stats
+	// Partial is set when the scan was cancelled before every repo was
+	// read. The repos it didn't get to aren't in Failed.
+	Partial bool
+	// SampledFrom is the number of repos listed when only a sample of
+	// Options.Sample of them was scanned, 0 otherwise.
+	SampledFrom int
+	// Budget is what the responses of the scan said was left of the rate
+	// limit, the zero Budget when they didn't say.
+	Budget Budget
+	// CutShort lists the repos with more commits than Options.MaxCommits
+	// let the scan read, by name.
+	CutShort []string
+}
+
+// RepoError is a repo that could not be scanned completely.
+type RepoError struct {
+	Repo string
+	// Contacts holds whatever was read before the error.
+	Contacts []Contact
+	Err      error
+}
+
+func (e RepoError) Error() string { return fmt.Sprintf("%s: %v", e.Repo, e.Err) }
+func (e RepoError) Unwrap() error { return e.Err }
+
+// provider lists repos and reads their contacts on one forge. The
+// scanner itself is the GitHub provider.
+type provider interface {
+	getRepos(ctx context.Context, user string) ([]string, error)
+	getRepoEmails(ctx context.Context, repo string) ([]Contact, error)
+	getRepoContributors(ctx context.Context, repo string) ([]Contact, error)
+}
+
+// scanner holds the settings of one Sniff call.
+type scanner struct {
+	opts     Options
+	client   *http.Client
+	limiter  Limiter
+	dedupKey func(Contact) string
+	cacheDir string
+	log      *slog.Logger
+	forge    provider
+	counters counters
+	budget   budgetTracker
+	// cut lists the repos Options.MaxCommits cut short.
+	cut struct {
+		sync.Mutex
+		repos []string
+	}
+	// sampledFrom is the length of the listing the repos scanned were
+	// sampled from, 0 when they weren't.
+	sampledFrom int
+
+	// The endpoints derived from Options.BaseURL.
+	baseAPI, baseRepos, baseUsers, baseUser, baseOrgs, baseGraphQL string
+}
+
+func newScanner(opts Options) (*scanner, error) {
+	s := &scanner{opts: opts, client: opts.Client, limiter: opts.Limiter, log: opts.Logger}
+	if s.client == nil {
+		s.client = http.DefaultClient
+	}
+	if s.log == nil {
+		s.log = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	if s.opts.UserAgent == "" {
+		s.opts.UserAgent = "github-sniffer"
+	}
+	switch {
+	case opts.Timeout < 0:
+		return nil, fmt.Errorf("timeout must be positive")
+	case opts.Timeout == 0:
+		s.opts.Timeout = 10 * time.Second
+	}
+	if s.limiter == nil {
+		n := opts.Concurrency
+		if n == 0 {
+			n = 8
+		}
+		if n < 1 {
+			return nil, fmt.Errorf("concurrency must be at least 1")
+		}
+		s.limiter = NewLimiter(n)
+	}
+
+	switch opts.Source {
+	case "", "commits":
+	case "contributors":
+		if !opts.Since.IsZero() || !opts.Until.IsZero() {
+			return nil, fmt.Errorf("since and until need the commits source")
+		}
+		if opts.FirstParent {
+			return nil, fmt.Errorf("first-parent needs the commits source")
+		}
+	default:
+		return nil, fmt.Errorf("unknown source %q, want commits or contributors", opts.Source)
+	}
+
+	if opts.IncludePrivate && opts.Auth == "" {
+		return nil, fmt.Errorf("private repos need a token")
+	}
+	if opts.IncludeGists {
+		if _, err := exec.LookPath("git"); err != nil {
+			return nil, fmt.Errorf("gists are read with git: %w", err)
+		}
+	}
+
+	switch opts.Provider {
+	case "", "github":
+		s.forge = s
+	case "gitlab":
+		switch {
+		case opts.IncludePrivate:
+			return nil, fmt.Errorf("private repos are only supported on GitHub")
+		case opts.IncludeEvents:
+			return nil, fmt.Errorf("events are only supported on GitHub")
+		case opts.IncludeGists:
+			return nil, fmt.Errorf("gists are only supported on GitHub")
+		case opts.GraphQL:
+			return nil, fmt.Errorf("graphql is only supported on GitHub")
+		case opts.ResolveLogins:
+			return nil, fmt.Errorf("login search is only supported on GitHub")
+		}
+		s.forge = gitlab{s}
+	case "bitbucket":
+		switch {
+		case opts.Source == "contributors":
+			return nil, fmt.Errorf("bitbucket has no contributors listing, use the commits source")
+		case opts.IncludeEvents:
+			return nil, fmt.Errorf("events are only supported on GitHub")
+		case opts.IncludeGists:
+			return nil, fmt.Errorf("gists are only supported on GitHub")
+		case opts.GraphQL:
+			return nil, fmt.Errorf("graphql is only supported on GitHub")
+		case opts.ResolveLogins:
+			return nil, fmt.Errorf("login search is only supported on GitHub")
+		case opts.FirstParent:
+			return nil, fmt.Errorf("first-parent is not supported on Bitbucket")
+		}
+		s.forge = bitbucket{s}
+	default:
+		return nil, fmt.Errorf("unknown provider %q, want github, gitlab or bitbucket", opts.Provider)
+	}
+
+	key := opts.DedupKey
+	if key == "" {
+		key = "email"
+	}
+	var ok bool
+	if s.dedupKey, ok = dedupKeys[key]; !ok {
+		return nil, fmt.Errorf("unknown dedup key %q, want one of %s", key, DedupKeyNames())
+	}
+	for _, pattern := range opts.ExcludeRepos {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("bad exclude pattern %q: %w", pattern, err)
+		}
+	}
+	for _, pattern := range opts.Placeholders {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("bad placeholder pattern %q: %w", pattern, err)
+		}
+	}
+
+	if err := s.setBaseURL(opts.BaseURL); err != nil {
+		return nil, err
+	}
+
+	s.cacheDir = opts.CacheDir
+	return s, nil
+}
+
+// setBaseURL points every endpoint at the API root raw.
+func (s *scanner) setBaseURL(raw string) error {
+	onGitLab := s.opts.Provider == "gitlab"
+	onBitbucket := s.opts.Provider == "bitbucket"
+	if raw == "" {
+		switch {
+		case onGitLab:
+			raw = DefaultGitLabURL
+		case onBitbucket:
+			raw = DefaultBitbucketURL
+		default:
+			raw = DefaultBaseURL
+		}
+	}
+	u, err := neturl.Parse(strings.TrimRight(raw, "/"))
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("base url %q needs a scheme and host", raw)
+	}
+	switch {
+	case u.Path != "":
+	case onGitLab:
+		u.Path = "/api/v4"
+	case onBitbucket:
+		u.Path = "/2.0"
+	case u.Host != "api.github.com":
+		u.Path = "/api/v3"
+	}
+
+	base := u.String()
+	s.baseAPI = base
+	// GitHub Enterprise Server serves GraphQL next to /api/v3, not below.
+	s.baseGraphQL = strings.TrimSuffix(base, "/v3") + "/graphql"
+	s.baseRepos = base + "/repos"
+	s.baseUsers = base + "/users"
+	s.baseUser = base + "/user"
+	s.baseOrgs = base + "/orgs"
+	return nil
+}
+
+// AuthenticatedLogin resolves the login of the account opts.Auth belongs
+// to.
+func AuthenticatedLogin(ctx context.Context, opts Options) (string, error) {
+	s, err := newScanner(opts)
+	if err != nil {
+		return "", err
+	}
+	return s.getLogin(ctx, s.baseUser)
+}
+
+// ListRepos returns the full names of the repos Sniff would scan for
+// user, without reading any of their commits. A sample is the same one
+// Sniff picks only with Options.SampleSeed set.
+func ListRepos(ctx context.Context, user string, opts Options) ([]string, error) {
+	s, err := newScanner(opts)
+	if err != nil {
+		return nil, err
+	}
+	if user, err = opts.ParseUser(user); err != nil {
+		return nil, err
+	}
+	repos, err := s.forge.getRepos(listing(ctx), user)
+	if err != nil {
+		return nil, err
+	}
+	return s.sample(repos), nil
+}
+
+// Sniff collects the contacts of every repo of user, most active first.
+// user goes through Options.ParseUser first. Repos that fail are listed in
+// Result.Failed; an error is only returned when nothing could be scanned
+// at all. When ctx is cancelled once the repos are listed, what was read
+// until then comes back as a Partial result.
+func Sniff(ctx context.Context, user string, opts Options) (Result, error) {
+	s, err := newScanner(opts)
+	if err != nil {
+		return Result{}, err
+	}
+	if user, err = opts.ParseUser(user); err != nil {
+		return Result{}, err
+	}
+	return s.sniff(ctx, user)
+}
+
+func (s *scanner) sniff(ctx context.Context, user string) (Result, error) {
+	collect := s.collectREST
+	if s.opts.GraphQL && s.opts.Auth != "" && s.opts.Source != "contributors" && len(s.opts.Repos) == 0 && !s.opts.FirstParent && s.opts.Sample == 0 {
+		// GraphQL refuses anonymous requests, REST is the fallback. It
+		// also can't batch a hand picked set of repos, nor follow first
+		// parents, and it reads the commits of repos as it lists them,
+		// too early to sample.
+		collect = s.collectGraphQL
+	}
+	repos, results, err := collect(ctx, user)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// Merge in listing order so the outcome doesn't depend on which
+	// worker finished first.
+	r := Result{User: user, Contacts: []Contact{}, Failed: []RepoError{}, SampledFrom: s.sampledFrom}
+	// Only GitHub redirects renamed accounts. The projects of a GitLab
+	// subgroup would pass for a rename to the parent group.
+	if s.forge == provider(s) {
+		r.RenamedTo = renamedTo(user, repos)
+	}
+	if r.RenamedTo != "" {
+		s.log.Warn("user was renamed", "user", user, "now", r.RenamedTo)
+	}
+	seen := make(map[string]int)
+	r.Partial = ctx.Err() != nil
+	for _, repo := range repos {
+		repoEmails := results[repo]
+		// Whatever a failed repo managed to read is still kept.
+		r.Contacts = mergeContacts(r.Contacts, seen, s.dedupKey, repoEmails.Contacts)
+		if repoEmails.Err != nil && !(r.Partial && errors.Is(repoEmails.Err, ctx.Err())) {
+			r.Failed = append(r.Failed, repoEmails)
+		}
+	}
+	if r.Partial {
+		// Events and login lookups would only fail now.
+		r = s.finish(r)
+		r.Stats = s.counters.stats()
+		r.Budget = s.budget.get()
+		r.CutShort = s.cutRepos()
+		return r, nil
+	}
+
+	if len(repos) > 0 && len(r.Failed) == len(repos) {
+		return Result{}, r.Failed[0].Err
+	}
+	if s.opts.IncludeEvents {
+		// Pushes can carry addresses the repo walk misses, like those of
+		// since deleted or private repos.
+		var events []Contact
+		err := s.limited(ctx, func() (err error) {
+			events, err = s.getEventEmails(ctx, user)
+			return err
+		})
+		r.Contacts = mergeContacts(r.Contacts, seen, s.dedupKey, events)
+		if err != nil {
+			r.Failed = append(r.Failed, RepoError{Repo: "public events", Contacts: events, Err: err})
+		}
+	}
+	if s.opts.IncludeGists {
+		// Gists are git repos of their own the repo listing leaves out.
+		for _, gist := range s.collectGists(ctx, user) {
+			r.Contacts = mergeContacts(r.Contacts, seen, s.dedupKey, gist.Contacts)
+			if gist.Err != nil {
+				r.Failed = append(r.Failed, gist)
+			}
+		}
+	}
+	r = s.finish(r)
+	s.resolveLogins(ctx, r.Contacts)
+	if s.opts.ResolveLogins {
+		s.searchLogins(ctx, r.Contacts)
+	}
+	r.Stats = s.counters.stats()
+	r.Budget = s.budget.get()
+	r.CutShort = s.cutRepos()
+	return r, nil
+}
+
+// finish applies MinCommits to the merged contacts of r, flags their
+// placeholders and sorts them, most active first.
+func (s *scanner) finish(r Result) Result {
+	s.markPlaceholders(r.Contacts)
+	if s.opts.MinCommits > 0 {
+		// Only now are the counts totals over every repo.
+		r.Contacts = slices.DeleteFunc(r.Contacts, func(c Contact) bool { return c.Count < s.opts.MinCommits })
+	}
+	// Ties stay in the order they were found.
+	sort.SliceStable(r.Contacts, func(i, j int) bool { return r.Contacts[i].Count > r.Contacts[j].Count })
+	return r
+}
+
+// collectREST lists the repos of user, unless Options.Repos names them,
+// and fetches their contacts, one worker per limiter slot. The results are keyed by repo.
+func (s *scanner) collectREST(ctx context.Context, user string) ([]string, map[string]RepoError, error) {
+	repos := s.opts.Repos
+	if len(repos) == 0 {
+		err := s.limited(ctx, func() (err error) {
+			repos, err = s.forge.getRepos(listing(ctx), user)
+			return err
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		repos = s.sample(repos)
+	}
+	s.progress(0, len(repos))
+
+	repoEmailsChan := make(chan RepoError, len(repos))
+	var wg sync.WaitGroup
+	jobs := make(chan string)
+	for range cap(s.limiter) {
+		wg.Add(1)
+		go func(c chan RepoError) {
+			defer wg.Done()
+			for repo := range jobs {
+				// Calls sharing the limiter share the limit.
+				if err := s.acquire(ctx); err != nil {
+					c <- RepoError{Repo: repo, Err: err}
+					continue
+				}
+				if s.opts.RepoStart != nil {
+					s.opts.RepoStart(repo)
+				}
+				var repoEmails []Contact
+				var err error
+				if s.opts.Source == "contributors" {
+					repoEmails, err = s.forge.getRepoContributors(ctx, repo)
+				} else {
+					repoEmails, err = s.forge.getRepoEmails(ctx, repo)
+				}
+				s.release()
+				s.markPlaceholders(repoEmails)
+				r := RepoError{Repo: repo, Contacts: repoEmails, Err: err}
+				s.repoDone(r)
+				c <- r
+			}
+		}(repoEmailsChan)
+	}
+	go func() {
+		for _, repo := range repos {
+			jobs <- repo
+		}
+		close(jobs)
+		wg.Wait()
+		close(repoEmailsChan)
+	}()
+
+	results := make(map[string]RepoError, len(repos))
+	done := 0
+	for repoEmails := range repoEmailsChan {
+		results[repoEmails.Repo] = repoEmails
+		done++
+		s.progress(done, len(repos))
+	}
+	return repos, results, nil
+}
+
+// sample picks Options.Sample of repos at random, kept in listing order,
+// and records how many there were. Listings no longer than that are
+// scanned whole.
+func (s *scanner) sample(repos []string) []string {
+	n := s.opts.Sample
+	if n <= 0 || len(repos) <= n {
+		return repos
+	}
+	seed := s.opts.SampleSeed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	picked := rand.New(rand.NewPCG(seed, 0)).Perm(len(repos))[:n]
+	slices.Sort(picked)
+	sampled := make([]string, n)
+	for i, p := range picked {
+		sampled[i] = repos[p]
+	}
+	s.sampledFrom = len(repos)
+	s.log.Info("sampled repos", "sampled", n, "of", len(repos))
+	return sampled
+}
+
+// excluded tells whether the repo fullName matches one of
+// Options.ExcludeRepos.
+func (s *scanner) excluded(fullName string) bool {
+	name := fullName[strings.LastIndexByte(fullName, '/')+1:]
+	for _, pattern := range s.opts.ExcludeRepos {
+		target := name
+		if strings.Contains(pattern, "/") {
+			target = fullName
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// renamedTo returns the new name of user when every repo listed for it
+// is owned under another name, which happens when the old name redirects.
+func renamedTo(user string, repos []string) string {
+	var owner string
+	for _, repo := range repos {
+		o, _, _ := strings.Cut(repo, "/")
+		if owner != "" && o != owner {
+			return ""
+		}
+		owner = o
+	}
+	if strings.EqualFold(owner, user) {
+		return ""
+	}
+	return owner
+}
+
+// acquire takes a limiter slot, or gives up when ctx is done.
+func (s *scanner) acquire(ctx context.Context) error {
+	select {
+	case s.limiter <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *scanner) release() { <-s.limiter }
+
+// listingDeadline bounds a listing with Options.Timeout: one deadline for
+// the whole listing, however many pages it takes.
+func (s *scanner) listingDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, s.opts.Timeout)
+}
+
+// limited runs f in a limiter slot. Listings, events and login lookups
+// take one like the repos do, so a batch of users sharing a Limiter never
+// has more requests in flight than it allows.
+func (s *scanner) limited(ctx context.Context, f func() error) error {
+	if err := s.acquire(ctx); err != nil {
+		return err
+	}
+	defer s.release()
+	return f()
+}
+
+// cutShort notes that Options.MaxCommits left commits of repo unread.
+func (s *scanner) cutShort(repo string) {
+	s.cut.Lock()
+	defer s.cut.Unlock()
+	s.cut.repos = append(s.cut.repos, repo)
+}
+
+// cutRepos returns the repos noted by cutShort, sorted.
+func (s *scanner) cutRepos() []string {
+	s.cut.Lock()
+	defer s.cut.Unlock()
+	repos := slices.Clone(s.cut.repos)
+	slices.Sort(repos)
+	return repos
+}
+
+func (s *scanner) repoDone(r RepoError) {
+	if s.opts.RepoDone != nil {
+		s.opts.RepoDone(r)
+	}
+}
+
+func (s *scanner) progress(done, total int) {
+	if s.opts.Progress != nil {
+		s.opts.Progress(done, total)
+	}
+}
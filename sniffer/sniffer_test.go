@@ -0,0 +1,1566 @@
+package sniffer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// newTestScanner returns a scanner talking to a local server running h,
+// mounted under the /api/v3 prefix setBaseURL adds for non-GitHub hosts.
+func newTestScanner(t *testing.T, h http.Handler) (*scanner, *httptest.Server) {
+	t.Helper()
+
+	srv := httptest.NewServer(http.StripPrefix("/api/v3", h))
+	t.Cleanup(srv.Close)
+	s, err := newScanner(Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, srv
+}
+
+func writeJSON(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, body)
+}
+
+func TestGetRepos(t *testing.T) {
+	mux := http.NewServeMux()
+	var srvURL string
+	mux.HandleFunc("/users/octocat/repos", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			writeJSON(w, `[{"full_name": "octocat/c"}]`)
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<%s/api/v3/users/octocat/repos?page=2>; rel="next"`, srvURL))
+		writeJSON(w, `[{"full_name": "octocat/a"}, {"full_name": "octocat/b"}]`)
+	})
+	s, srv := newTestScanner(t, mux)
+	srvURL = srv.URL
+
+	repos, err := s.getRepos(context.Background(), "octocat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"octocat/a", "octocat/b", "octocat/c"}
+	if !slices.Equal(repos, want) {
+		t.Errorf("got %v, want %v", repos, want)
+	}
+}
+
+func TestGetReposCached(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.StripPrefix("/api/v3", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		writeJSON(w, `[{"full_name": "octocat/a"}]`)
+	})))
+	defer srv.Close()
+
+	opts := Options{BaseURL: srv.URL, CacheDir: t.TempDir(), CacheTTL: time.Hour}
+	for range 2 {
+		s, err := newScanner(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		repos, err := s.getRepos(context.Background(), "octocat")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(repos, []string{"octocat/a"}) {
+			t.Errorf("got %v, want [octocat/a]", repos)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("server hit %d times, want 1", hits)
+	}
+
+	// Another token may see other repos.
+	opts.Auth = "token"
+	s, _ := newScanner(opts)
+	s.getRepos(context.Background(), "octocat")
+	if hits != 2 {
+		t.Errorf("server hit %d times with another token, want 2", hits)
+	}
+}
+
+func TestSniffReposCacheTTL(t *testing.T) {
+	listings, commits := 0, 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/octocat/repos", func(w http.ResponseWriter, r *http.Request) {
+		listings++
+		writeJSON(w, `[{"full_name": "octocat/a"}]`)
+	})
+	mux.HandleFunc("/repos/octocat/a/commits", func(w http.ResponseWriter, r *http.Request) {
+		commits++
+		writeJSON(w, `[{"commit": {"author": {"name": "Cat", "email": "cat@example.com"}, "committer": {"name": "Cat", "email": "cat@example.com"}}}]`)
+	})
+	srv := httptest.NewServer(http.StripPrefix("/api/v3", mux))
+	defer srv.Close()
+
+	opts := Options{BaseURL: srv.URL, CacheDir: t.TempDir(), ReposCacheTTL: time.Hour}
+	for range 2 {
+		if _, err := Sniff(context.Background(), "octocat", opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if listings != 1 || commits != 2 {
+		t.Errorf("listed %d times and read commits %d times, want 1 and 2", listings, commits)
+	}
+}
+
+func TestPruneCache(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-2 * time.Hour)
+	for name, mtime := range map[string]time.Time{"old.json": old, "old.tmp": old, "new.json": time.Now(), "notes.txt": old} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("{}"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := PruneCache(dir, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var left []string
+	for _, e := range entries {
+		left = append(left, e.Name())
+	}
+	if want := []string{"new.json", "notes.txt"}; !slices.Equal(left, want) {
+		t.Errorf("left %v, want %v", left, want)
+	}
+	if err := PruneCache(filepath.Join(dir, "missing"), time.Hour); err != nil {
+		t.Errorf("missing dir: %v", err)
+	}
+}
+
+func TestGetReposETag(t *testing.T) {
+	hits, notModified := 0, 0
+	srv := httptest.NewServer(http.StripPrefix("/api/v3", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		writeJSON(w, `[{"full_name": "octocat/a"}]`)
+	})))
+	defer srv.Close()
+
+	// Without a TTL every request is revalidated.
+	opts := Options{BaseURL: srv.URL, CacheDir: t.TempDir()}
+	for range 2 {
+		s, err := newScanner(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		repos, err := s.getRepos(context.Background(), "octocat")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(repos, []string{"octocat/a"}) {
+			t.Errorf("got %v, want [octocat/a]", repos)
+		}
+	}
+	if hits != 2 || notModified != 1 {
+		t.Errorf("got %d requests and %d revalidations, want 2 and 1", hits, notModified)
+	}
+}
+
+func TestGetReposExcluded(t *testing.T) {
+	s, _ := newTestScanner(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `[{"full_name": "octocat/a"}, {"full_name": "octocat/old-archive"}, {"full_name": "octocat/dotfiles"}, {"full_name": "octocat/b"}]`)
+	}))
+	s.opts.ExcludeRepos = []string{"*-archive", "dotfiles", "octocat/b"}
+
+	repos, err := s.getRepos(context.Background(), "octocat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(repos, []string{"octocat/a"}) {
+		t.Errorf("got %v, want [octocat/a]", repos)
+	}
+
+	if _, err := newScanner(Options{ExcludeRepos: []string{"["}}); err == nil {
+		t.Error("bad pattern accepted")
+	}
+}
+
+func TestGetReposNotFound(t *testing.T) {
+	s, _ := newTestScanner(t, http.NotFoundHandler())
+
+	_, err := s.getRepos(context.Background(), "nobody")
+	var status StatusError
+	if !errors.As(err, &status) || status.Code != http.StatusNotFound {
+		t.Errorf("got %v, want a 404 StatusError", err)
+	}
+	var notFound UserNotFoundError
+	if !errors.As(err, &notFound) || notFound.User != "nobody" {
+		t.Errorf("got %v, want a UserNotFoundError for nobody", err)
+	}
+}
+
+func TestGetReposErrorTypes(t *testing.T) {
+	s, _ := newTestScanner(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		writeJSON(w, `{"message": "Bad credentials"}`)
+	}))
+	_, err := s.getRepos(context.Background(), "octocat")
+	var auth AuthError
+	if !errors.As(err, &auth) || auth.Code != http.StatusUnauthorized {
+		t.Errorf("got %v, want a 401 AuthError", err)
+	}
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	s, err = newScanner(Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.getRepos(context.Background(), "octocat")
+	var network NetworkError
+	if !errors.As(err, &network) {
+		t.Errorf("got %v, want a NetworkError", err)
+	}
+}
+
+func TestGetReposMalformed(t *testing.T) {
+	page := "<html><body>" + strings.Repeat("Unicorn! ", 100) + "</body></html>"
+	s, _ := newTestScanner(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, page)
+	}))
+
+	_, err := s.getRepos(context.Background(), "octocat")
+	var decode DecodeError
+	if !errors.As(err, &decode) {
+		t.Fatalf("got %v, want a DecodeError", err)
+	}
+	if decode.Code != http.StatusOK || !strings.HasPrefix(decode.Body, "<html><body>Unicorn!") {
+		t.Errorf("got status %d and body %q", decode.Code, decode.Body)
+	}
+	if len(decode.Body) > maxSnippet+len("…") {
+		t.Errorf("body of %d bytes, want at most %d", len(decode.Body), maxSnippet)
+	}
+}
+
+func TestGetReposTruncated(t *testing.T) {
+	const body = `[{"full_name": "octocat/a"}]`
+	attempts := 0
+	srv := httptest.NewServer(http.StripPrefix("/api/v3", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			// Promise the whole body, send half and hang up.
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(body[:10]))
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		writeJSON(w, body)
+	})))
+	defer srv.Close()
+
+	s, err := newScanner(Options{BaseURL: srv.URL, MaxRetries: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repos, err := s.getRepos(context.Background(), "octocat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(repos, []string{"octocat/a"}) || attempts != 2 {
+		t.Errorf("got %v after %d attempts, want [octocat/a] after 2", repos, attempts)
+	}
+
+	// Without retries the broken read is a NetworkError.
+	attempts = 0
+	s, _ = newScanner(Options{BaseURL: srv.URL})
+	_, err = s.getRepos(context.Background(), "octocat")
+	var network NetworkError
+	if !errors.As(err, &network) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("got %v, want a NetworkError for an unexpected EOF", err)
+	}
+}
+
+func TestGetReposRateLimited(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	s, _ := newTestScanner(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprint(reset.Unix()))
+		w.WriteHeader(http.StatusForbidden)
+		writeJSON(w, `{"message": "API rate limit exceeded"}`)
+	}))
+
+	_, err := s.getRepos(context.Background(), "octocat")
+	var rateLimit RateLimitError
+	if !errors.As(err, &rateLimit) {
+		t.Fatalf("got %v, want a RateLimitError", err)
+	}
+	if !rateLimit.ResetAt.Equal(reset) {
+		t.Errorf("reset at %v, want %v", rateLimit.ResetAt, reset)
+	}
+}
+
+func TestGetReposSecondaryLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       int
+		retryAfter string
+		body       string
+		secondary  bool
+	}{
+		{"retry-after", http.StatusForbidden, "60", `{"message": "slow down"}`, true},
+		{"message", http.StatusForbidden, "", `{"message": "You have exceeded a secondary rate limit."}`, true},
+		{"too many requests", http.StatusTooManyRequests, "", `{}`, true},
+		{"no access", http.StatusForbidden, "", `{"message": "Resource not accessible by personal access token"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestScanner(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.code)
+				writeJSON(w, tt.body)
+			}))
+			_, err := s.getRepos(context.Background(), "octocat")
+			var rateLimit RateLimitError
+			var auth AuthError
+			switch {
+			case tt.secondary && (!errors.As(err, &rateLimit) || !rateLimit.Secondary):
+				t.Errorf("got %v, want a secondary RateLimitError", err)
+			case tt.secondary && tt.retryAfter != "" && time.Until(rateLimit.ResetAt) < 50*time.Second:
+				t.Errorf("retry at %v, want a minute from now", rateLimit.ResetAt)
+			case !tt.secondary && !errors.As(err, &auth):
+				t.Errorf("got %v, want an AuthError", err)
+			}
+		})
+	}
+}
+
+func TestFetchBudget(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rate_limit", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization %q, want the token", got)
+		}
+		writeJSON(w, fmt.Sprintf(`{"resources": {"core": {"limit": 5000, "remaining": 4999, "reset": %d}}}`, reset.Unix()))
+	})
+	srv := httptest.NewServer(http.StripPrefix("/api/v3", mux))
+	t.Cleanup(srv.Close)
+
+	b, err := FetchBudget(context.Background(), Options{BaseURL: srv.URL, Auth: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Budget{Remaining: 4999, Limit: 5000, Reset: reset}
+	if b.Remaining != want.Remaining || b.Limit != want.Limit || !b.Reset.Equal(want.Reset) {
+		t.Errorf("got %+v, want %+v", b, want)
+	}
+}
+
+func TestGetRepoEmails(t *testing.T) {
+	s, _ := newTestScanner(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/octocat/a/commits" {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, `[
+			{"commit": {"author": {"name": "Octo", "email": "octo@example.com"}, "committer": {"name": "Octo", "email": "octo@example.com"}}},
+			{"commit": {"author": {"name": "Octo", "email": "octo@example.com"}, "committer": {"name": "GitHub", "email": "noreply@github.com"}}},
+			{"commit": {"author": {"name": "Cat", "email": "cat@example.com"}, "committer": {"name": "Cat", "email": "cat@example.com"}}}
+		]`)
+	}))
+
+	contacts, err := s.getRepoEmails(context.Background(), "octocat/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var emails []string
+	for _, c := range contacts {
+		emails = append(emails, c.Email)
+	}
+	want := []string{"octo@example.com", "noreply@github.com", "cat@example.com"}
+	if !slices.Equal(emails, want) {
+		t.Errorf("got %v, want %v", emails, want)
+	}
+	if contacts[0].Count != 2 {
+		t.Errorf("octo@example.com counted %d times, want 2", contacts[0].Count)
+	}
+}
+
+func TestGetRepoEmailsTimeoutPerPage(t *testing.T) {
+	var srvURL string
+	s, srv := newTestScanner(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every page is in time, all of them together are not.
+		time.Sleep(40 * time.Millisecond)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 3 {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/api/v3/repos/octocat/a/commits?page=%d>; rel="next"`, srvURL, page+1))
+		}
+		// Full pages, shorter ones end the listing.
+		commit := fmt.Sprintf(`{"commit": {"author": {"name": "Cat", "email": "cat%d@example.com"}, "committer": {"name": "Cat", "email": "cat%d@example.com"}}}`, page, page)
+		writeJSON(w, "["+strings.Repeat(commit+",", 99)+commit+"]")
+	}))
+	srvURL = srv.URL
+	s.opts.Timeout = 100 * time.Millisecond
+
+	contacts, err := s.getRepoEmails(context.Background(), "octocat/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contacts) != 4 {
+		t.Errorf("got %v, want all 4 pages read", contacts)
+	}
+}
+
+func TestGetRepoEmailsFirstParent(t *testing.T) {
+	s, _ := newTestScanner(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Maint merged the branch of Dev into the first commit of Root.
+		writeJSON(w, `[
+			{"sha": "m", "parents": [{"sha": "r"}, {"sha": "d"}], "commit": {"author": {"name": "Maint", "email": "maint@example.com"}, "committer": {"name": "Maint", "email": "maint@example.com"}}},
+			{"sha": "d", "parents": [{"sha": "r"}], "commit": {"author": {"name": "Dev", "email": "dev@example.com"}, "committer": {"name": "Dev", "email": "dev@example.com"}}},
+			{"sha": "r", "parents": [], "commit": {"author": {"name": "Root", "email": "root@example.com"}, "committer": {"name": "Root", "email": "root@example.com"}}}
+		]`)
+	}))
+	s.opts.FirstParent = true
+
+	contacts, err := s.getRepoEmails(context.Background(), "octocat/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var emails []string
+	for _, c := range contacts {
+		emails = append(emails, c.Email)
+	}
+	if want := []string{"maint@example.com", "root@example.com"}; !slices.Equal(emails, want) {
+		t.Errorf("got %v, want %v", emails, want)
+	}
+}
+
+func TestGetRepoEmailsEmptyRepo(t *testing.T) {
+	s, _ := newTestScanner(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		writeJSON(w, `{"message": "Git Repository is empty."}`)
+	}))
+
+	contacts, err := s.getRepoEmails(context.Background(), "octocat/empty")
+	if err != nil {
+		t.Fatalf("got %v, want no error", err)
+	}
+	if len(contacts) != 0 {
+		t.Errorf("got %v, want no contacts", contacts)
+	}
+}
+
+func TestGetRepoEmailsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		h    http.HandlerFunc
+	}{
+		{"not found", func(w http.ResponseWriter, r *http.Request) {
+			http.NotFound(w, r)
+		}},
+		{"malformed json", func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, `<html>unicorn</html>`)
+		}},
+		{"rate limited", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.WriteHeader(http.StatusForbidden)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestScanner(t, tt.h)
+
+			if _, err := s.getRepoEmails(context.Background(), "octocat/a"); err == nil {
+				t.Error("want an error")
+			}
+		})
+	}
+}
+
+func TestMergeContacts(t *testing.T) {
+	contacts := []Contact{
+		{Name: "John", Email: "john@x.com", Count: 1},
+		{Name: "jdoe", Email: "john@x.com", Count: 1},
+		{Name: "John", Email: "john@y.com", Count: 1},
+	}
+	tests := []struct {
+		key  string
+		want []Contact
+	}{
+		{"email", []Contact{
+			{Name: "John", Email: "john@x.com", Count: 2},
+			{Name: "John", Email: "john@y.com", Count: 1},
+		}},
+		{"email+name", []Contact{
+			{Name: "John", Email: "john@x.com", Count: 1},
+			{Name: "jdoe", Email: "john@x.com", Count: 1},
+			{Name: "John", Email: "john@y.com", Count: 1},
+		}},
+		{"name", []Contact{
+			{Name: "John", Email: "john@x.com", Count: 2},
+			{Name: "jdoe", Email: "john@x.com", Count: 1},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got := mergeContacts(nil, make(map[string]int), dedupKeys[tt.key], contacts)
+			if tt.key == "email" && !slices.Equal(got[0].Aliases, []string{"jdoe"}) {
+				t.Errorf("got aliases %q, want [jdoe]", got[0].Aliases)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Name != tt.want[i].Name || got[i].Email != tt.want[i].Email || got[i].Count != tt.want[i].Count {
+					t.Errorf("contact %d: got %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDedupKeys(t *testing.T) {
+	tests := []struct {
+		key  string
+		a, b Contact
+		same bool
+	}{
+		{"email", Contact{Name: "John", Email: "john@x.com"}, Contact{Name: "jdoe", Email: "john@x.com"}, true},
+		{"email", Contact{Name: "John", Email: "john@x.com"}, Contact{Name: "John", Email: "john@y.com"}, false},
+		{"email+name", Contact{Name: "John", Email: "john@x.com"}, Contact{Name: "John", Email: "john@x.com"}, true},
+		{"email+name", Contact{Name: "John", Email: "john@x.com"}, Contact{Name: "jdoe", Email: "john@x.com"}, false},
+		{"email+name", Contact{Name: "John", Email: "john@x.com"}, Contact{Name: "John", Email: "john@y.com"}, false},
+		{"name", Contact{Name: "John", Email: "john@x.com"}, Contact{Name: "John", Email: "john@y.com"}, true},
+		{"name", Contact{Name: "John", Email: "john@x.com"}, Contact{Name: "jdoe", Email: "john@x.com"}, false},
+		{"name", Contact{Email: "john@x.com"}, Contact{Email: "jane@x.com"}, false},
+		{"name", Contact{Email: "john@x.com"}, Contact{Email: "john@x.com"}, true},
+		{"name", Contact{Email: "john@x.com"}, Contact{Name: "john@x.com", Email: "jane@x.com"}, false},
+	}
+	for _, tt := range tests {
+		key := dedupKeys[tt.key]
+		if same := key(tt.a) == key(tt.b); same != tt.same {
+			t.Errorf("%s: %v and %v same = %v, want %v", tt.key, tt.a, tt.b, same, tt.same)
+		}
+	}
+}
+
+func TestMergeContactsRepoCommits(t *testing.T) {
+	contacts := []Contact{
+		{Email: "john@x.com", Repos: []string{"a"}, Count: 1},
+		{Email: "john@x.com", Repos: []string{"a"}, Count: 2},
+		{Email: "john@x.com", Repos: []string{"b"}, Count: 1},
+	}
+	got := mergeContacts(nil, make(map[string]int), dedupKeys["email"], contacts)
+	want := map[string]int{"a": 3, "b": 1}
+	if len(got) != 1 || !maps.Equal(got[0].RepoCommits, want) {
+		t.Fatalf("got %v, want repo commits %v", got, want)
+	}
+
+	// Merging merged contacts, as across repos, adds their breakdowns up.
+	other := Contact{Email: "john@x.com", Repos: []string{"a", "c"}, Count: 3, RepoCommits: map[string]int{"a": 1, "c": 2}}
+	got = mergeContacts(got, map[string]int{"john@x.com": 0}, dedupKeys["email"], []Contact{other})
+	want = map[string]int{"a": 4, "b": 1, "c": 2}
+	if !maps.Equal(got[0].RepoCommits, want) {
+		t.Errorf("got %v, want %v", got[0].RepoCommits, want)
+	}
+}
+
+func TestSniff(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/octocat/repos", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `[{"full_name": "octocat/a"}, {"full_name": "octocat/b"}, {"full_name": "octocat/broken"}]`)
+	})
+	mux.HandleFunc("/repos/octocat/a/commits", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `[{"commit": {"author": {"name": "Cat", "email": "cat@example.com"}, "committer": {"name": "Cat", "email": "cat@example.com"}}}]`)
+	})
+	mux.HandleFunc("/repos/octocat/b/commits", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `[
+			{"commit": {"author": {"name": "Octo", "email": "octo@example.com"}, "committer": {"name": "Octo", "email": "octo@example.com"}}},
+			{"commit": {"author": {"name": "Octo", "email": "octo@example.com"}, "committer": {"name": "Octo", "email": "octo@example.com"}}}
+		]`)
+	})
+	srv := httptest.NewServer(http.StripPrefix("/api/v3", mux))
+	defer srv.Close()
+
+	r, err := Sniff(context.Background(), "octocat", Options{BaseURL: srv.URL, Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var emails []string
+	for _, c := range r.Contacts {
+		emails = append(emails, c.Email)
+	}
+	want := []string{"octo@example.com", "cat@example.com"}
+	if !slices.Equal(emails, want) {
+		t.Errorf("got %v, want %v", emails, want)
+	}
+	if len(r.Failed) != 1 || r.Failed[0].Repo != "octocat/broken" {
+		t.Errorf("failed %v, want octocat/broken", r.Failed)
+	}
+
+	// Octo has two commits in total, Cat one.
+	r, err = Sniff(context.Background(), "octocat", Options{BaseURL: srv.URL, MinCommits: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Contacts) != 1 || r.Contacts[0].Email != "octo@example.com" {
+		t.Errorf("with MinCommits 2 got %v, want only octo@example.com", r.Contacts)
+	}
+}
+
+func TestSniffCancelled(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/octocat/repos", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `[{"full_name": "octocat/a"}, {"full_name": "octocat/slow"}]`)
+	})
+	mux.HandleFunc("/repos/octocat/a/commits", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `[{"commit": {"author": {"name": "Cat", "email": "cat@example.com"}, "committer": {"name": "Cat", "email": "cat@example.com"}}}]`)
+	})
+	mux.HandleFunc("/repos/octocat/slow/commits", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	srv := httptest.NewServer(http.StripPrefix("/api/v3", mux))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r, err := Sniff(ctx, "octocat", Options{BaseURL: srv.URL, Concurrency: 2, RepoDone: func(r RepoError) {
+		if r.Repo == "octocat/a" {
+			cancel()
+		}
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Partial {
+		t.Error("result not marked partial")
+	}
+	if len(r.Contacts) != 1 || r.Contacts[0].Email != "cat@example.com" {
+		t.Errorf("got %v, want cat@example.com", r.Contacts)
+	}
+	if len(r.Failed) != 0 {
+		t.Errorf("failed %v, want the cancelled repo left out", r.Failed)
+	}
+}
+
+func TestSniffSample(t *testing.T) {
+	var mu sync.Mutex
+	var scanned []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/octocat/repos", func(w http.ResponseWriter, r *http.Request) {
+		names := make([]string, 10)
+		for i := range names {
+			names[i] = fmt.Sprintf(`{"full_name": "octocat/r%d"}`, i)
+		}
+		writeJSON(w, "["+strings.Join(names, ",")+"]")
+	})
+	mux.HandleFunc("/repos/octocat/{repo}/commits", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		scanned = append(scanned, "octocat/"+r.PathValue("repo"))
+		mu.Unlock()
+		writeJSON(w, `[]`)
+	})
+	srv := httptest.NewServer(http.StripPrefix("/api/v3", mux))
+	defer srv.Close()
+
+	opts := Options{BaseURL: srv.URL, Sample: 3, SampleSeed: 42}
+	r, err := Sniff(context.Background(), "octocat", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.SampledFrom != 10 {
+		t.Errorf("sampled from %d, want 10", r.SampledFrom)
+	}
+	slices.Sort(scanned)
+	if len(scanned) != 3 {
+		t.Fatalf("scanned %v, want 3 repos", scanned)
+	}
+	// The same seed picks the same repos.
+	listed, err := ListRepos(context.Background(), "octocat", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(listed, scanned) {
+		t.Errorf("listed %v, scanned %v with the same seed", listed, scanned)
+	}
+
+	opts.Sample = 20
+	if r, err = Sniff(context.Background(), "octocat", opts); err != nil {
+		t.Fatal(err)
+	}
+	if r.SampledFrom != 0 {
+		t.Errorf("sampled from %d, want the whole listing scanned", r.SampledFrom)
+	}
+}
+
+func TestSniffBudget(t *testing.T) {
+	sniff := func(remaining int) (Result, []Budget) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-RateLimit-Limit", "5000")
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+			w.Header().Set("X-RateLimit-Reset", "1700000000")
+			if r.URL.Path == "/users/octocat/repos" {
+				writeJSON(w, `[{"full_name": "octocat/a"}]`)
+				return
+			}
+			writeJSON(w, `[]`)
+		})
+		srv := httptest.NewServer(http.StripPrefix("/api/v3", mux))
+		defer srv.Close()
+
+		var mu sync.Mutex
+		var changes []Budget
+		r, err := Sniff(context.Background(), "octocat", Options{BaseURL: srv.URL, BudgetChanged: func(b Budget) {
+			mu.Lock()
+			defer mu.Unlock()
+			changes = append(changes, b)
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r, changes
+	}
+
+	// Scans don't see each other's budget, as with different tokens.
+	for _, remaining := range []int{4000, 10} {
+		r, changes := sniff(remaining)
+		if r.Budget.Remaining != remaining || r.Budget.Limit != 5000 {
+			t.Errorf("budget %v, want %d/5000", r.Budget, remaining)
+		}
+		if len(changes) != 1 || changes[0] != r.Budget {
+			t.Errorf("changes %v, want just %v", changes, r.Budget)
+		}
+	}
+}
+
+func TestSniffCutShort(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/octocat/repos", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `[{"full_name": "octocat/a"}, {"full_name": "octocat/b"}]`)
+	})
+	mux.HandleFunc("/repos/octocat/a/commits", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `[
+			{"commit": {"author": {"name": "Octo", "email": "octo@example.com"}, "committer": {"name": "Octo", "email": "octo@example.com"}}},
+			{"commit": {"author": {"name": "Octo", "email": "octo@example.com"}, "committer": {"name": "Octo", "email": "octo@example.com"}}}
+		]`)
+	})
+	mux.HandleFunc("/repos/octocat/b/commits", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `[{"commit": {"author": {"name": "Cat", "email": "cat@example.com"}, "committer": {"name": "Cat", "email": "cat@example.com"}}}]`)
+	})
+	srv := httptest.NewServer(http.StripPrefix("/api/v3", mux))
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		max  int
+		want []string
+	}{
+		{1, []string{"octocat/a"}},
+		{2, nil},
+		{0, nil},
+	} {
+		r, err := Sniff(context.Background(), "octocat", Options{BaseURL: srv.URL, MaxCommits: tt.max})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(r.CutShort, tt.want) {
+			t.Errorf("MaxCommits %d: cut short %v, want %v", tt.max, r.CutShort, tt.want)
+		}
+	}
+}
+
+func TestSniffGists(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git")
+	}
+	// A local repo stands in for gist.github.com.
+	gist := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"-c", "commit.gpgsign=false", "commit", "--quiet", "--allow-empty", "-m", "first"},
+		{"-c", "commit.gpgsign=false", "commit", "--quiet", "--allow-empty", "-m", "second"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = gist
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Gister", "GIT_AUTHOR_EMAIL=Gister@example.com",
+			"GIT_COMMITTER_NAME=Gister", "GIT_COMMITTER_EMAIL=gister@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/octocat/repos", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `[]`)
+	})
+	mux.HandleFunc("/users/octocat/gists", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := json.Marshal([]GistDataPiece{
+			{ID: "abc", GitPullURL: gist},
+			{ID: "gone", GitPullURL: filepath.Join(t.TempDir(), "missing")},
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+	srv := httptest.NewServer(http.StripPrefix("/api/v3", mux))
+	defer srv.Close()
+
+	r, err := Sniff(context.Background(), "octocat", Options{BaseURL: srv.URL, IncludeGists: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Contacts) != 1 || r.Contacts[0].Email != "gister@example.com" || r.Contacts[0].Count != 2 {
+		t.Errorf("got %+v, want gister@example.com with 2 commits", r.Contacts)
+	}
+	if len(r.Contacts) == 1 && !slices.Equal(r.Contacts[0].Repos, []string{"gist:abc"}) {
+		t.Errorf("repos %v, want gist:abc", r.Contacts[0].Repos)
+	}
+	if len(r.Failed) != 1 || r.Failed[0].Repo != "gist:gone" {
+		t.Errorf("failed %v, want gist:gone", r.Failed)
+	}
+
+	r, err = Sniff(context.Background(), "octocat", Options{BaseURL: srv.URL, IncludeGists: true, MaxCommits: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Contacts) != 1 || r.Contacts[0].Count != 1 || !slices.Equal(r.CutShort, []string{"gist:abc"}) {
+		t.Errorf("with MaxCommits 1 got %+v cut short %v, want one commit and gist:abc", r.Contacts, r.CutShort)
+	}
+
+	if _, err := Sniff(context.Background(), "octocat", Options{Provider: "gitlab", IncludeGists: true}); err == nil {
+		t.Error("gists accepted on GitLab")
+	}
+}
+
+func TestGitProxy(t *testing.T) {
+	proxy, _ := neturl.Parse("http://proxy:3128")
+	tests := []struct {
+		name      string
+		transport http.RoundTripper
+		want      string
+		wantOK    bool
+	}{
+		{"proxy", &http.Transport{Proxy: http.ProxyURL(proxy)}, "http://proxy:3128", true},
+		{"no proxy", &http.Transport{}, "", true},
+		{"unknown transport", struct{ http.RoundTripper }{}, "", false},
+	}
+	for _, tt := range tests {
+		s, err := newScanner(Options{Client: &http.Client{Transport: tt.transport}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, ok := s.gitProxy("https://gist.github.com/abc.git")
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("%s: got %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestSniffPlaceholders(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/octocat/repos", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `[{"full_name": "octocat/a"}]`)
+	})
+	mux.HandleFunc("/repos/octocat/a/commits", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `[
+			{"commit": {"author": {"name": "Root", "email": "root@box"}, "committer": {"name": "Root", "email": "root@box"}}},
+			{"commit": {"author": {"name": "Build", "email": "build@CI.corp"}, "committer": {"name": "Build", "email": "build@CI.corp"}}},
+			{"commit": {"author": {"name": "Cat", "email": "cat@cats.io"}, "committer": {"name": "Cat", "email": "cat@cats.io"}}}
+		]`)
+	})
+	srv := httptest.NewServer(http.StripPrefix("/api/v3", mux))
+	defer srv.Close()
+
+	r, err := Sniff(context.Background(), "octocat", Options{BaseURL: srv.URL, Placeholders: []string{"*@ci.corp"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"root@box": true, "build@ci.corp": true, "cat@cats.io": false}
+	for _, c := range r.Contacts {
+		if c.Placeholder != want[c.Email] {
+			t.Errorf("%s placeholder = %v, want %v", c.Email, c.Placeholder, want[c.Email])
+		}
+	}
+
+	if _, err := Sniff(context.Background(), "octocat", Options{BaseURL: srv.URL, Placeholders: []string{"["}}); err == nil {
+		t.Error("bad pattern accepted")
+	}
+}
+
+func TestParseUser(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"octocat", "octocat"},
+		{" octocat\n", "octocat"},
+		{"@octocat", "octocat"},
+		{"https://github.com/octocat", "octocat"},
+		{"https://github.com/octocat/", "octocat"},
+		{"github.com/octocat/Hello-World", "octocat"},
+		{"https://www.github.com/octocat?tab=repositories", "octocat"},
+		{"my-org-1", "my-org-1"},
+		{"", ""},
+		{"octo cat", ""},
+		{"-octocat", ""},
+		{"octocat-", ""},
+		{"octo_cat", ""},
+		{strings.Repeat("a", 40), ""},
+	}
+	for _, tt := range tests {
+		got, err := ParseUser(tt.in)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("ParseUser(%q) = %q, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseUser(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseRepo(t *testing.T) {
+	tests := []struct {
+		provider, in, owner, fullName string
+	}{
+		{"", "octocat/Hello-World", "octocat", "octocat/Hello-World"},
+		{"", "https://github.com/octocat/Hello-World/tree/main", "octocat", "octocat/Hello-World"},
+		{"", "github.com/octocat/dotfiles.git", "octocat", "octocat/dotfiles"},
+		{"", "octocat", "", ""},
+		{"", "octo cat/repo", "", ""},
+		{"gitlab", "https://gitlab.com/gitlab-org/security/gitlab/-/tree/master", "gitlab-org/security", "gitlab-org/security/gitlab"},
+		{"bitbucket", "bitbucket.org/Atlassian/python-bitbucket/src", "atlassian", "atlassian/python-bitbucket"},
+	}
+	for _, tt := range tests {
+		owner, fullName, err := Options{Provider: tt.provider}.ParseRepo(tt.in)
+		if tt.owner == "" {
+			if err == nil {
+				t.Errorf("ParseRepo(%q) = %q, want an error", tt.in, fullName)
+			}
+			continue
+		}
+		if err != nil || owner != tt.owner || fullName != tt.fullName {
+			t.Errorf("ParseRepo(%q) = %q, %q, %v, want %q, %q", tt.in, owner, fullName, err, tt.owner, tt.fullName)
+		}
+	}
+}
+
+func TestSniffGraphQL(t *testing.T) {
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/api/graphql" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			Query     string         `json:"query"`
+			Variables map[string]any `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		queries = append(queries, body.Query)
+
+		if body.Variables["owner"] == "octocat" {
+			// The second page of the history of octocat/b.
+			writeJSON(w, `{"data": {"repository": {"defaultBranchRef": {"target": {"history": {
+				"nodes": [{"author": {"name": "Octo", "email": "octo@example.com"}, "committer": {"name": "Octo", "email": "octo@example.com"}}],
+				"pageInfo": {"hasNextPage": false}
+			}}}}}}`)
+			return
+		}
+		writeJSON(w, `{"data": {"owner": {"repositories": {
+			"totalCount": 3,
+			"nodes": [
+				{"nameWithOwner": "octocat/a", "defaultBranchRef": {"target": {"history": {
+					"nodes": [{"author": {"name": "Cat", "email": "cat@example.com"}, "committer": {"name": "Cat", "email": "cat@example.com"}}],
+					"pageInfo": {"hasNextPage": false}
+				}}}},
+				{"nameWithOwner": "octocat/b", "defaultBranchRef": {"target": {"history": {
+					"nodes": [{"author": {"name": "Octo", "email": "octo@example.com"}, "committer": {"name": "Octo", "email": "octo@example.com"}}],
+					"pageInfo": {"hasNextPage": true, "endCursor": "c1"}
+				}}}},
+				{"nameWithOwner": "octocat/empty", "defaultBranchRef": null}
+			],
+			"pageInfo": {"hasNextPage": false}
+		}}}}`)
+	}))
+	defer srv.Close()
+
+	r, err := Sniff(context.Background(), "octocat", Options{BaseURL: srv.URL, Auth: "token", GraphQL: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) != 2 {
+		t.Errorf("made %d queries, want 2", len(queries))
+	}
+	var emails []string
+	for _, c := range r.Contacts {
+		emails = append(emails, c.Email)
+	}
+	want := []string{"octo@example.com", "cat@example.com"}
+	if !slices.Equal(emails, want) {
+		t.Errorf("got %v, want %v", emails, want)
+	}
+	if r.Contacts[0].Count != 2 {
+		t.Errorf("octo@example.com counted %d times, want 2", r.Contacts[0].Count)
+	}
+	if len(r.Failed) != 0 {
+		t.Errorf("failed %v, want none", r.Failed)
+	}
+}
+
+func TestGetRepoEmailsNormalized(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `[
+			{"commit": {"author": {"name": "Foo", "email": "Foo@Example.com"}, "committer": {"name": "Foo", "email": "foo@example.com "}}},
+			{"commit": {"author": {"name": "Foo", "email": "foo@example.com"}, "committer": {"name": "Foo", "email": "foo@example.com"}}}
+		]`)
+	})
+	tests := []struct {
+		caseSensitive bool
+		want          []string
+	}{
+		{false, []string{"foo@example.com"}},
+		{true, []string{"Foo@Example.com", "foo@example.com ", "foo@example.com"}},
+	}
+	for _, tt := range tests {
+		s, _ := newTestScanner(t, h)
+		s.opts.CaseSensitive = tt.caseSensitive
+
+		contacts, err := s.getRepoEmails(context.Background(), "octocat/a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var emails []string
+		for _, c := range contacts {
+			emails = append(emails, c.Email)
+		}
+		if !slices.Equal(emails, tt.want) {
+			t.Errorf("case sensitive %v: got %q, want %q", tt.caseSensitive, emails, tt.want)
+		}
+	}
+}
+
+func TestGetReposIncludePrivate(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `{"login": "octocat"}`)
+	})
+	mux.HandleFunc("/user/repos", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `[{"full_name": "octocat/public"}, {"full_name": "octocat/secret"}]`)
+	})
+	mux.HandleFunc("/users/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `[{"full_name": "other/public"}]`)
+	})
+	srv := httptest.NewServer(http.StripPrefix("/api/v3", mux))
+	defer srv.Close()
+
+	tests := []struct {
+		user string
+		want []string
+	}{
+		{"OctoCat", []string{"octocat/public", "octocat/secret"}},
+		{"other", []string{"other/public"}},
+	}
+	for _, tt := range tests {
+		repos, err := ListRepos(context.Background(), tt.user, Options{BaseURL: srv.URL, Auth: "token", IncludePrivate: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(repos, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.user, repos, tt.want)
+		}
+	}
+
+	if _, err := ListRepos(context.Background(), "octocat", Options{BaseURL: srv.URL, IncludePrivate: true}); err == nil {
+		t.Error("want an error without a token")
+	}
+}
+
+func TestKeep(t *testing.T) {
+	s, err := newScanner(Options{
+		Filter:  regexp.MustCompile(`@example\.com$`),
+		Exclude: regexp.MustCompile(`^ci@`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		email string
+		want  bool
+	}{
+		{"octo@example.com", true},
+		{"Octo@Example.com", true},
+		{"octo@example.org", false},
+		{"ci@example.com", false},
+		{"1+octo@users.noreply.github.com", false},
+	}
+	for _, tt := range tests {
+		if got := s.keep("", tt.email); got != tt.want {
+			t.Errorf("keep(%q) = %v, want %v", tt.email, got, tt.want)
+		}
+	}
+}
+
+func TestIsBot(t *testing.T) {
+	tests := []struct {
+		name, email string
+		want        bool
+	}{
+		{"dependabot[bot]", "49699333+dependabot[bot]@users.noreply.github.com", true},
+		{"github-actions", "41898282+github-actions[bot]@users.noreply.github.com", true},
+		{"GitHub Action", "action@github.com", true},
+		{"Renovate Bot", "bot@renovateapp.com", true},
+		{"Octo", "octo@example.com", false},
+		{"Robot Builder", "robot@example.com", false},
+	}
+	for _, tt := range tests {
+		if got := isBot(tt.name, tt.email); got != tt.want {
+			t.Errorf("isBot(%q, %q) = %v, want %v", tt.name, tt.email, got, tt.want)
+		}
+	}
+}
+
+func TestSniffGitLab(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/v4/users/gitlab-org/projects":
+			http.NotFound(w, r)
+		case "/api/v4/groups/gitlab-org/projects":
+			writeJSON(w, `[
+				{"path_with_namespace": "gitlab-org/a"},
+				{"path_with_namespace": "gitlab-org/fork", "forked_from_project": {"id": 1}}
+			]`)
+		case "/api/v4/projects/gitlab-org%2Fa/repository/commits":
+			writeJSON(w, `[
+				{"author_name": "Tanuki", "author_email": "tanuki@example.com", "committer_name": "Tanuki", "committer_email": "tanuki@example.com"},
+				{"author_name": "Fox", "author_email": "fox@example.com", "committer_name": "Tanuki", "committer_email": "tanuki@example.com"}
+			]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	r, err := Sniff(context.Background(), "gitlab-org", Options{Provider: "gitlab", BaseURL: srv.URL, NoForks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var emails []string
+	for _, c := range r.Contacts {
+		emails = append(emails, c.Email)
+	}
+	want := []string{"tanuki@example.com", "fox@example.com"}
+	if !slices.Equal(emails, want) {
+		t.Errorf("got %v, want %v", emails, want)
+	}
+	if len(r.Failed) != 0 {
+		t.Errorf("failed %v, want none", r.Failed)
+	}
+}
+
+func TestGetRepoEmailsLastActive(t *testing.T) {
+	s, _ := newTestScanner(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `[
+			{"commit": {"author": {"name": "Octo", "email": "octo@example.com", "date": "2023-05-11T10:00:00Z"}, "committer": {"name": "Octo", "email": "octo@example.com", "date": "2023-05-11T10:00:00Z"}}},
+			{"commit": {"author": {"name": "Octo", "email": "octo@example.com", "date": "2021-01-02T10:00:00Z"}, "committer": {"name": "Octo", "email": "octo@example.com", "date": "2021-01-02T10:00:00Z"}}}
+		]`)
+	}))
+
+	contacts, err := s.getRepoEmails(context.Background(), "octocat/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2023, 5, 11, 10, 0, 0, 0, time.UTC)
+	if len(contacts) != 1 || !contacts[0].LastActive.Equal(want) {
+		t.Errorf("got %v, want one contact last active %v", contacts, want)
+	}
+}
+
+func TestSniffRenamedUser(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/oldcat/repos", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/api/v3/users/newcat/repos", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/users/newcat/repos", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `[{"full_name": "newcat/a"}]`)
+	})
+	mux.HandleFunc("/repos/newcat/a/commits", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `[{"commit": {"author": {"name": "Cat", "email": "cat@example.com"}, "committer": {"name": "Cat", "email": "cat@example.com"}}}]`)
+	})
+	srv := httptest.NewServer(http.StripPrefix("/api/v3", mux))
+	defer srv.Close()
+
+	r, err := Sniff(context.Background(), "oldcat", Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.RenamedTo != "newcat" {
+		t.Errorf("renamed to %q, want newcat", r.RenamedTo)
+	}
+	if len(r.Contacts) != 1 || len(r.Failed) != 0 {
+		t.Errorf("got %v, failed %v, want cat@example.com", r.Contacts, r.Failed)
+	}
+}
+
+func TestSniffStats(t *testing.T) {
+	mux := http.NewServeMux()
+	handle := func(path, body string) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			writeJSON(w, body)
+		})
+	}
+	handle("/users/octocat/repos", `[{"full_name": "octocat/a"}, {"full_name": "octocat/b"}]`)
+	handle("/repos/octocat/a/commits", `[{"commit": {"author": {"name": "Cat", "email": "cat@example.com"}}}]`)
+	handle("/repos/octocat/b/commits", `[]`)
+	srv := httptest.NewServer(http.StripPrefix("/api/v3", mux))
+	defer srv.Close()
+
+	opts := Options{BaseURL: srv.URL, CacheDir: t.TempDir()}
+	want := []Stats{
+		{Requests: 3, CacheMisses: 3, RateLimitUsed: 3},
+		// The second scan revalidates everything.
+		{Requests: 3, CacheHits: 3, NotModified: 3},
+	}
+	for i, w := range want {
+		r, err := Sniff(context.Background(), "octocat", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Stats != w {
+			t.Errorf("scan %d: got %+v, want %+v", i+1, r.Stats, w)
+		}
+	}
+}
+
+func TestSearchLogins(t *testing.T) {
+	searches := 0
+	srv := httptest.NewServer(http.StripPrefix("/api/v3", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		searches++
+		if searches == 1 {
+			// The search limit is spent, until now.
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Query().Get("q") {
+		case "cat@example.com in:email":
+			writeJSON(w, `{"total_count": 1, "items": [{"login": "cat"}]}`)
+		default:
+			writeJSON(w, `{"total_count": 0, "items": []}`)
+		}
+	})))
+	defer srv.Close()
+
+	s, err := newScanner(Options{BaseURL: srv.URL, MaxRetries: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []Contact{
+		{Email: "cat@example.com"},
+		{Email: "nobody@example.com"},
+		{Email: "1+octo@users.noreply.github.com", Login: "octo"},
+	}
+	s.searchLogins(context.Background(), data)
+	var logins []string
+	for _, c := range data {
+		logins = append(logins, c.Login)
+	}
+	if want := []string{"cat", "", "octo"}; !slices.Equal(logins, want) {
+		t.Errorf("got %q, want %q", logins, want)
+	}
+	if searches != 3 {
+		t.Errorf("got %d searches, want 3", searches)
+	}
+}
+
+func TestGetRepoEmailsSigned(t *testing.T) {
+	s, _ := newTestScanner(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `[
+			{"commit": {"author": {"name": "Octo", "email": "octo@example.com"}, "committer": {"name": "Octo", "email": "octo@example.com"}, "verification": {"verified": true}}},
+			{"commit": {"author": {"name": "Octo", "email": "octo@example.com"}, "committer": {"name": "Octo", "email": "octo@example.com"}, "verification": {"verified": false}}},
+			{"commit": {"author": {"name": "Cat", "email": "cat@example.com"}, "committer": {"name": "Cat", "email": "cat@example.com"}}}
+		]`)
+	}))
+
+	contacts, err := s.getRepoEmails(context.Background(), "octocat/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := make(map[string]int)
+	for _, c := range contacts {
+		signed[c.Email] = c.Signed
+	}
+	if signed["octo@example.com"] != 1 || signed["cat@example.com"] != 0 {
+		t.Errorf("got signed counts %v, want octo 1 and cat 0", signed)
+	}
+}
+
+func TestGetRepoContributors(t *testing.T) {
+	s, _ := newTestScanner(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("anon") != "true" {
+			t.Errorf("anonymous contributors not asked for: %s", r.URL)
+		}
+		writeJSON(w, `[
+			{"login": "octocat", "type": "User", "contributions": 40},
+			{"type": "Anonymous", "name": "Cat", "email": "cat@example.com", "contributions": 3},
+			{"type": "Anonymous", "name": "Cat", "email": "Cat@Example.com ", "contributions": 2}
+		]`)
+	}))
+
+	contacts, err := s.getRepoContributors(context.Background(), "octocat/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contacts) != 1 || contacts[0].Email != "cat@example.com" || contacts[0].Count != 5 {
+		t.Errorf("got %v, want cat@example.com with 5 commits", contacts)
+	}
+}
+
+func TestGetRepoEmailsMissingEmail(t *testing.T) {
+	s, _ := newTestScanner(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `[
+			{"commit": {"author": null, "committer": {"name": "GitHub", "email": "  "}}},
+			{"commit": {"author": {"name": "Imported", "email": ""}, "committer": {"name": "Octo", "email": "octo@example.com"}}}
+		]`)
+	}))
+
+	for _, caseSensitive := range []bool{false, true} {
+		s.opts.CaseSensitive = caseSensitive
+		contacts, err := s.getRepoEmails(context.Background(), "octocat/a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(contacts) != 1 || contacts[0].Email != "octo@example.com" {
+			t.Errorf("case sensitive %v: got %v, want only octo@example.com", caseSensitive, contacts)
+		}
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	s, _ := newTestScanner(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, `[]`)
+	}))
+	s.opts.Throttle = rate.NewLimiter(20, 1)
+
+	start := time.Now()
+	for range 3 {
+		if _, err := s.getRepoEmails(context.Background(), "octocat/a"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first request goes right away, the others 50ms apart.
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 requests at 20 per second took %v, want at least 100ms", elapsed)
+	}
+}
+
+func TestSniffSharedLimiter(t *testing.T) {
+	var inFlight, most atomic.Int32
+	srv := httptest.NewServer(http.StripPrefix("/api/v3", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := most.Load()
+			if n <= m || most.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if strings.HasSuffix(r.URL.Path, "/repos") {
+			writeJSON(w, `[{"full_name": "x/a"}, {"full_name": "x/b"}]`)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/events/public") {
+			writeJSON(w, `[]`)
+			return
+		}
+		writeJSON(w, `[{"commit": {"author": {"name": "Cat", "email": "cat@cats.io"}, "committer": {"name": "Cat", "email": "cat@cats.io"}}}]`)
+	})))
+	defer srv.Close()
+
+	// Listings and events of a batch count against its limit too.
+	opts := Options{BaseURL: srv.URL, Limiter: NewLimiter(2), IncludeEvents: true}
+	var wg sync.WaitGroup
+	for _, user := range []string{"u1", "u2", "u3", "u4", "u5"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := Sniff(context.Background(), user, opts); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if m := most.Load(); m > 2 {
+		t.Errorf("%d requests in flight, want at most 2", m)
+	}
+}
+
+func TestSniffBitbucket(t *testing.T) {
+	var srvURL string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2.0/repositories/atlassian":
+			if r.URL.Query().Get("page") == "" {
+				writeJSON(w, `{"values": [{"full_name": "atlassian/a"}], "next": "`+srvURL+`/2.0/repositories/atlassian?page=2"}`)
+				return
+			}
+			writeJSON(w, `{"values": [{"full_name": "atlassian/fork", "parent": {"full_name": "other/fork"}}]}`)
+		case "/2.0/repositories/atlassian/a/commits":
+			if user, password, ok := r.BasicAuth(); !ok || user != "me" || password != "secret" {
+				t.Errorf("got basic auth %q %q, want the app password", user, password)
+			}
+			writeJSON(w, `{"values": [
+				{"date": "2024-03-01T10:00:00+00:00", "author": {"raw": "Jane Doe <jane@example.com>"}},
+				{"date": "2024-02-01T10:00:00+00:00", "author": {"raw": "J. Doe <jane@example.com>"}},
+				{"date": "2023-01-01T10:00:00+00:00", "author": {"raw": "Old <old@example.com>"}}
+			]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	srvURL = srv.URL
+
+	r, err := Sniff(context.Background(), "Atlassian", Options{
+		Provider: "bitbucket",
+		BaseURL:  srv.URL,
+		Auth:     "me:secret",
+		NoForks:  true,
+		Since:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Contacts) != 1 || r.Contacts[0].Email != "jane@example.com" || r.Contacts[0].Count != 2 {
+		t.Errorf("got %v, want jane@example.com with 2 commits", r.Contacts)
+	}
+	if r.RenamedTo != "" || len(r.Failed) != 0 {
+		t.Errorf("got renamed %q and failed %v, want neither", r.RenamedTo, r.Failed)
+	}
+}
+
+func TestParseRawAuthor(t *testing.T) {
+	tests := []struct{ raw, name, email string }{
+		{"Jane Doe <jane@example.com>", "Jane Doe", "jane@example.com"},
+		{"J. Doe <jane@example.com>", "J. Doe", "jane@example.com"},
+		{"<jane@example.com>", "", "jane@example.com"},
+		{"Jane Doe", "Jane Doe", ""},
+	}
+	for _, tt := range tests {
+		name, email := parseRawAuthor(tt.raw)
+		if name != tt.name || email != tt.email {
+			t.Errorf("parseRawAuthor(%q) = %q, %q, want %q, %q", tt.raw, name, email, tt.name, tt.email)
+		}
+	}
+}
+
+func TestResultWrite(t *testing.T) {
+	r := Result{User: "octocat", Contacts: []Contact{
+		{Name: "Cat", Email: "cat@example.com", Aliases: []string{"Kitty"}, Count: 3, Signed: 3, Placeholder: true, RepoCommits: map[string]int{"octocat/a": 2, "octocat/b": 1}, LastActive: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)},
+		{Name: "Dog", Email: "dog@dogs.io", Count: 1, RepoCommits: map[string]int{"octocat/b": 1}},
+	}, CutShort: []string{"octocat/a"}}
+	other := Result{User: "hubot", Contacts: []Contact{{Name: "Dog", Email: "dog@dogs.io", Count: 2}}}
+	both := Results{r, other}
+	evil := Result{User: `<script>alert("u")</script>`, Contacts: []Contact{
+		{Name: `Tom & "Jerry" <script>`, Email: `'x'@a&b.io`, Count: 1, RepoCommits: map[string]int{"o/<b>": 1}},
+	}}
+
+	tests := []struct {
+		name  string
+		write func(io.Writer) error
+		// want is the whole output; without it the output only has to
+		// contain every string of contains, and none of lacks.
+		want     string
+		contains []string
+		lacks    []string
+	}{
+		{name: "text", write: r.WriteText, want: "1.\t    3\tCat (aka Kitty) <cat@example.com> (placeholder) ✓ signed, last active: 2024-05-01\n2.\t    1\tDog <dog@dogs.io>\n"},
+		{name: "text of several users", write: both.WriteText, want: "octocat\n1.\t    3\tCat (aka Kitty) <cat@example.com> (placeholder) ✓ signed, last active: 2024-05-01\n2.\t    1\tDog <dog@dogs.io>\n\nhubot\n1.\t    2\tDog <dog@dogs.io>\n"},
+		{name: "plain", write: both.WritePlain, want: "cat@example.com\ndog@dogs.io\n"},
+		{name: "csv", write: r.WriteCSV, want: "user,email,name,count,last_active,aliases\noctocat,cat@example.com,Cat,3,2024-05-01,Kitty\noctocat,dog@dogs.io,Dog,1,,\n"},
+		{name: "matrix", write: r.WriteMatrix, want: "user,email,octocat/a,octocat/b\noctocat,cat@example.com,2,1\noctocat,dog@dogs.io,0,1\n"},
+		{name: "markdown", write: r.WriteMarkdown, want: "| Email | Name | Commits |\n| --- | --- | ---: |\n| cat@example.com | Cat (aka Kitty) | 3 |\n| dog@dogs.io | Dog | 1 |\n"},
+		{name: "vcf", write: both.WriteVCF, want: "BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Cat\r\nN:;Cat;;;\r\nNICKNAME:Kitty\r\nEMAIL;TYPE=INTERNET:cat@example.com\r\nEND:VCARD\r\n" +
+			"BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Dog\r\nN:;Dog;;;\r\nEMAIL;TYPE=INTERNET:dog@dogs.io\r\nEND:VCARD\r\n"},
+		{name: "json", write: r.WriteJSON, contains: []string{"{\n  \"user\": \"octocat\"", `"last_active": "2024-05-01"`, `"placeholder": true`, `"domain": "dogs.io"`, "\"meta\": {\n    \"requests\": 0,", "\"cut_short\": [\n      \"octocat/a\"\n    ]"}},
+		{name: "json of several users", write: both.WriteJSON, contains: []string{"[\n  {\n    \"user\": \"octocat\"", `"user": "hubot"`}},
+		{name: "jsonl", write: both.WriteJSONL, contains: []string{"{\"user\":\"octocat\",", "}\n{\"user\":\"hubot\","}},
+		{name: "html", write: r.WriteHTML, contains: []string{"<h1>octocat</h1>", `class="placeholder"`, "<td>Cat (aka Kitty)</td>", "<th>Repos</th>", "octocat/a (2), octocat/b (1)"}},
+		{name: "html escaped", write: evil.WriteHTML,
+			contains: []string{
+				"<h1>&lt;script&gt;alert(&#34;u&#34;)&lt;/script&gt;</h1>",
+				"<td>Tom &amp; &#34;Jerry&#34; &lt;script&gt;</td>",
+				"<td>&#39;x&#39;@a&amp;b.io</td>",
+				"o/&lt;b&gt; (1)",
+			},
+			lacks: []string{"<script>alert", `"Jerry"`, "<b>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := tt.write(&b); err != nil {
+				t.Fatal(err)
+			}
+			got := b.String()
+			if tt.want != "" && got != tt.want {
+				t.Errorf("got\n%q\nwant\n%q", got, tt.want)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(got, s) {
+					t.Errorf("got\n%s\nwant it to contain %q", got, s)
+				}
+			}
+			for _, s := range tt.lacks {
+				if strings.Contains(got, s) {
+					t.Errorf("got\n%s\nwant it without %q", got, s)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package sniffer
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Stats counts what a scan cost.
+type Stats struct {
+	// Requests is the number of HTTP requests sent, retries included.
+	Requests int `json:"requests"`
+	// CacheHits counts responses served from the cache, whether fresh
+	// or revalidated with a 304; CacheMisses the cacheable ones that had
+	// to be downloaded.
+	CacheHits   int `json:"cache_hits"`
+	CacheMisses int `json:"cache_misses"`
+	// NotModified counts the 304 answers to revalidations.
+	NotModified int `json:"not_modified"`
+	// Retries counts the requests sent again after a Retry-After or a
+	// 5xx.
+	Retries int `json:"retries"`
+	// RateLimitUsed is how many of the requests counted against the rate
+	// limit: all but the 304s. A GraphQL query counts once, though it may
+	// cost GitHub more points.
+	RateLimitUsed int `json:"rate_limit_used"`
+}
+
+func (st Stats) String() string {
+	return fmt.Sprintf("%d requests (%d retried), %d cache hits, %d misses, %d not modified, %d against the rate limit",
+		st.Requests, st.Retries, st.CacheHits, st.CacheMisses, st.NotModified, st.RateLimitUsed)
+}
+
+// counters accumulates the Stats of a scan from its concurrent workers.
+type counters struct {
+	requests, cacheHits, cacheMisses, notModified, retries, rateLimitUsed atomic.Int64
+}
+
+func (c *counters) stats() Stats {
+	return Stats{
+		Requests:      int(c.requests.Load()),
+		CacheHits:     int(c.cacheHits.Load()),
+		CacheMisses:   int(c.cacheMisses.Load()),
+		NotModified:   int(c.notModified.Load()),
+		Retries:       int(c.retries.Load()),
+		RateLimitUsed: int(c.rateLimitUsed.Load()),
+	}
+}
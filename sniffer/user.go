@@ -0,0 +1,131 @@
+package sniffer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// userPattern is GitHub's rule for user and organization names:
+// alphanumerics and hyphens, not starting or ending with a hyphen.
+var userPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?$`)
+
+// maxUserLen is the longest name GitHub accepts.
+const maxUserLen = 39
+
+// ParseUser cleans up a username as typed or pasted, accepting
+// "octocat", "@octocat" and "https://github.com/octocat", and checks it
+// against GitHub's naming rules.
+func ParseUser(s string) (string, error) {
+	user := strings.TrimSpace(s)
+	for _, prefix := range []string{"https://", "http://", "www.", "github.com/", "@"} {
+		user = strings.TrimPrefix(user, prefix)
+	}
+	// A pasted repo or profile tab URL still names the owner first.
+	user, _, _ = strings.Cut(user, "/")
+	user, _, _ = strings.Cut(user, "?")
+
+	switch {
+	case user == "":
+		return "", fmt.Errorf("username is empty")
+	case len(user) > maxUserLen:
+		return "", fmt.Errorf("%q is not a valid username: longer than %d characters", user, maxUserLen)
+	case !userPattern.MatchString(user):
+		return "", fmt.Errorf("%q is not a valid username: only letters, digits and inner hyphens are allowed", user)
+	}
+	return user, nil
+}
+
+// repoNamePattern is the rule all three forges share for the name of a
+// repo, without its owner.
+var repoNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// gitlabUserPattern is GitLab's rule for user and group paths, subgroups
+// included: letters, digits, underscores, dots and hyphens.
+var gitlabUserPattern = regexp.MustCompile(`^[A-Za-z0-9_.][A-Za-z0-9_.-]*(/[A-Za-z0-9_.][A-Za-z0-9_.-]*)*$`)
+
+// ParseGitLabUser is ParseUser for GitLab users and groups, which may be
+// nested like "gitlab-org/security".
+func ParseGitLabUser(s string) (string, error) {
+	user := strings.TrimSpace(s)
+	for _, prefix := range []string{"https://", "http://", "www.", "gitlab.com/", "@"} {
+		user = strings.TrimPrefix(user, prefix)
+	}
+	// Pages below a group or user hang off "/-/".
+	user, _, _ = strings.Cut(user, "/-/")
+	user, _, _ = strings.Cut(user, "?")
+	user = strings.TrimRight(user, "/")
+
+	switch {
+	case user == "":
+		return "", fmt.Errorf("username is empty")
+	case !gitlabUserPattern.MatchString(user):
+		return "", fmt.Errorf("%q is not a valid GitLab user or group", user)
+	}
+	return user, nil
+}
+
+// bitbucketWorkspacePattern is Bitbucket's rule for workspace IDs:
+// letters, digits, underscores and hyphens.
+var bitbucketWorkspacePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ParseBitbucketWorkspace is ParseUser for Bitbucket workspaces.
+// Workspace IDs are lowercase, so the result is too.
+func ParseBitbucketWorkspace(s string) (string, error) {
+	user := strings.TrimSpace(s)
+	for _, prefix := range []string{"https://", "http://", "www.", "bitbucket.org/", "@"} {
+		user = strings.TrimPrefix(user, prefix)
+	}
+	user, _, _ = strings.Cut(user, "/")
+	user, _, _ = strings.Cut(user, "?")
+
+	switch {
+	case user == "":
+		return "", fmt.Errorf("workspace is empty")
+	case !bitbucketWorkspacePattern.MatchString(user):
+		return "", fmt.Errorf("%q is not a valid Bitbucket workspace", user)
+	}
+	return strings.ToLower(user), nil
+}
+
+// ParseUser checks a username typed or pasted for the provider scanned,
+// with ParseGitLabUser for GitLab, ParseBitbucketWorkspace for Bitbucket
+// and ParseUser otherwise.
+func (o Options) ParseUser(s string) (string, error) {
+	switch o.Provider {
+	case "gitlab":
+		return ParseGitLabUser(s)
+	case "bitbucket":
+		return ParseBitbucketWorkspace(s)
+	}
+	return ParseUser(s)
+}
+
+// ParseRepo checks a repo typed or pasted as "owner/name" or as its URL
+// for the provider scanned, and returns the owner, as ParseUser would,
+// and the full name. GitLab owners may be nested groups.
+func (o Options) ParseRepo(s string) (owner, fullName string, err error) {
+	path := strings.TrimSpace(s)
+	for _, prefix := range []string{"https://", "http://", "www.", "github.com/", "gitlab.com/", "bitbucket.org/"} {
+		path = strings.TrimPrefix(path, prefix)
+	}
+	path, _, _ = strings.Cut(path, "?")
+	// GitLab pages below a project hang off "/-/".
+	path, _, _ = strings.Cut(path, "/-/")
+	path = strings.TrimSuffix(strings.TrimRight(path, "/"), ".git")
+	if o.Provider != "gitlab" {
+		// Pages like /tree/main follow owner/name.
+		if parts := strings.SplitN(path, "/", 3); len(parts) == 3 {
+			path = parts[0] + "/" + parts[1]
+		}
+	}
+
+	i := strings.LastIndexByte(path, '/')
+	if i < 0 || !repoNamePattern.MatchString(path[i+1:]) {
+		return "", "", fmt.Errorf("%q is not a valid repo, want owner/name", s)
+	}
+	if owner, err = o.ParseUser(path[:i]); err != nil {
+		return "", "", err
+	}
+	return owner, owner + "/" + path[i+1:], nil
+}
@@ -0,0 +1,388 @@
+package sniffer
+
+import (
+	"crypto/md5"
+	_ "embed"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Results are the Results of one run, one per scanned user. Their Write
+// methods render all of them in one document, each user headed by its
+// name where the format has headings; those of a single Result render it
+// alone.
+type Results []Result
+
+func (r Result) WriteText(w io.Writer) error     { return Results{r}.WriteText(w) }
+func (r Result) WritePlain(w io.Writer) error    { return Results{r}.WritePlain(w) }
+func (r Result) WriteJSON(w io.Writer) error     { return Results{r}.WriteJSON(w) }
+func (r Result) WriteJSONL(w io.Writer) error    { return Results{r}.WriteJSONL(w) }
+func (r Result) WriteCSV(w io.Writer) error      { return Results{r}.WriteCSV(w) }
+func (r Result) WriteMatrix(w io.Writer) error   { return Results{r}.WriteMatrix(w) }
+func (r Result) WriteHTML(w io.Writer) error     { return Results{r}.WriteHTML(w) }
+func (r Result) WriteMarkdown(w io.Writer) error { return Results{r}.WriteMarkdown(w) }
+func (r Result) WriteVCF(w io.Writer) error      { return Results{r}.WriteVCF(w) }
+
+// WriteText writes a numbered list of the contacts, most active first,
+// as the interactive UI shows them.
+func (rs Results) WriteText(w io.Writer) error {
+	for n, r := range rs {
+		if len(rs) > 1 {
+			sep := "\n"
+			if n == 0 {
+				sep = ""
+			}
+			if _, err := fmt.Fprintf(w, "%s%s\n", sep, r.User); err != nil {
+				return err
+			}
+		}
+		for i, c := range r.Contacts {
+			line := c.String()
+			if c.Placeholder {
+				line += " (placeholder)"
+			}
+			if signed := c.SignedLabel(); signed != "" {
+				line += " " + signed
+			}
+			if date := c.LastActiveDate(); date != "" {
+				line += ", last active: " + date
+			}
+			if _, err := fmt.Fprintf(w, "%d.\t%5d\t%s\n", i+1, c.Count, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WritePlain writes the bare addresses, one per line, for pipes. An
+// address found for several users is written once.
+func (rs Results) WritePlain(w io.Writer) error {
+	seen := make(map[string]bool)
+	for _, r := range rs {
+		for _, c := range r.Contacts {
+			if seen[c.Email] {
+				continue
+			}
+			seen[c.Email] = true
+			if _, err := fmt.Fprintln(w, c.Email); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type jsonContact struct {
+	Contact
+	// LastActive is a plain date, left out when unknown. It shadows the
+	// timestamp of Contact.
+	LastActive string `json:"last_active,omitempty"`
+}
+
+// SignedLabel tells whether all or some of the commits of c are signed,
+// "" when none are.
+func (c Contact) SignedLabel() string {
+	switch {
+	case c.Signed == 0:
+		return ""
+	case c.Signed >= c.Count:
+		return "✓ signed"
+	}
+	return "✓ partly signed"
+}
+
+// LastActiveDate formats the LastActive date of c, "" when unknown.
+func (c Contact) LastActiveDate() string {
+	if c.LastActive.IsZero() {
+		return ""
+	}
+	return c.LastActive.Format(time.DateOnly)
+}
+
+// DomainCount is how many of the found addresses are at Domain.
+type DomainCount struct {
+	Domain string `json:"domain"`
+	Count  int    `json:"count"`
+}
+
+// CountDomains buckets the addresses of contacts by domain, biggest
+// first and ties by name.
+func CountDomains(contacts []Contact) []DomainCount {
+	counts := make(map[string]int)
+	for _, c := range contacts {
+		_, domain, _ := strings.Cut(c.Email, "@")
+		counts[strings.ToLower(domain)]++
+	}
+	domains := make([]DomainCount, 0, len(counts))
+	for d, n := range counts {
+		domains = append(domains, DomainCount{Domain: d, Count: n})
+	}
+	slices.SortFunc(domains, func(a, b DomainCount) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.Domain, b.Domain)
+	})
+	return domains
+}
+
+type jsonResult struct {
+	User      string        `json:"user"`
+	RenamedTo string        `json:"renamed_to,omitempty"`
+	Emails    []jsonContact `json:"emails"`
+	// Domains counts the emails by domain.
+	Domains []DomainCount `json:"domains"`
+	// Meta is what the scan cost, and which repos it left unfinished.
+	Meta jsonMeta `json:"meta"`
+	// Partial marks a scan cancelled before every repo was read.
+	Partial bool `json:"partial,omitempty"`
+	// SampledFrom is the number of repos listed when only a random
+	// sample of them was scanned.
+	SampledFrom int `json:"sampled_from,omitempty"`
+}
+
+type jsonMeta struct {
+	Stats
+	// CutShort lists the repos Options.MaxCommits stopped reading early.
+	CutShort []string `json:"cut_short,omitempty"`
+}
+
+func newJSONResult(r Result) jsonResult {
+	doc := jsonResult{User: r.User, RenamedTo: r.RenamedTo, Emails: make([]jsonContact, 0, len(r.Contacts)), Domains: CountDomains(r.Contacts), Meta: jsonMeta{r.Stats, r.CutShort}, Partial: r.Partial, SampledFrom: r.SampledFrom}
+	for _, c := range r.Contacts {
+		doc.Emails = append(doc.Emails, jsonContact{Contact: c, LastActive: c.LastActiveDate()})
+	}
+	return doc
+}
+
+// WriteJSON writes one indented object for a single user and an array
+// of them for several.
+func (rs Results) WriteJSON(w io.Writer) error {
+	docs := make([]jsonResult, len(rs))
+	for i, r := range rs {
+		docs[i] = newJSONResult(r)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if len(docs) == 1 {
+		return enc.Encode(docs[0])
+	}
+	return enc.Encode(docs)
+}
+
+// WriteJSONL writes the object of each user on a line of its own, so
+// big batches can be read as they are scanned.
+func (rs Results) WriteJSONL(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, r := range rs {
+		if err := enc.Encode(newJSONResult(r)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteCSV writes a row per contact under a header, the user first.
+func (rs Results) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"user", "email", "name", "count", "last_active", "aliases"})
+	for _, r := range rs {
+		for _, c := range r.Contacts {
+			cw.Write([]string{r.User, c.Email, c.Name, strconv.Itoa(c.Count), c.LastActiveDate(), strings.Join(c.Aliases, "; ")})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// RepoColumns lists the repos contacts committed to, most commits first
+// and ties by name.
+func RepoColumns(contacts []Contact) []string {
+	totals := make(map[string]int)
+	for _, c := range contacts {
+		for repo, n := range c.RepoCommits {
+			totals[repo] += n
+		}
+	}
+	repos := slices.Collect(maps.Keys(totals))
+	slices.SortFunc(repos, func(a, b string) int {
+		if totals[a] != totals[b] {
+			return totals[b] - totals[a]
+		}
+		return strings.Compare(a, b)
+	})
+	return repos
+}
+
+// WriteMatrix writes a CSV table of the commits of every address in
+// every repo, with a column per repo.
+func (rs Results) WriteMatrix(w io.Writer) error {
+	var all []Contact
+	for _, r := range rs {
+		all = append(all, r.Contacts...)
+	}
+	repos := RepoColumns(all)
+
+	cw := csv.NewWriter(w)
+	cw.Write(append([]string{"user", "email"}, repos...))
+	for _, r := range rs {
+		for _, c := range r.Contacts {
+			row := []string{r.User, c.Email}
+			for _, repo := range repos {
+				row = append(row, strconv.Itoa(c.RepoCommits[repo]))
+			}
+			cw.Write(row)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// gravatarURL returns the avatar URL gravatar serves for email.
+func gravatarURL(email string) string {
+	sum := md5.Sum([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return fmt.Sprintf("https://www.gravatar.com/avatar/%s?d=identicon", hex.EncodeToString(sum[:]))
+}
+
+//go:embed report.html
+var reportSource string
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"gravatar": gravatarURL,
+	"inc":      func(i int) int { return i + 1 },
+	"repos":    repoList,
+}).Parse(reportSource))
+
+// repoList lists the repos of c with their commits, most first, as in
+// "octocat/a (2), octocat/b (1)"; just the names when the counts are
+// unknown.
+func repoList(c Contact) string {
+	if len(c.RepoCommits) == 0 {
+		return strings.Join(c.Repos, ", ")
+	}
+	repos := RepoColumns([]Contact{c})
+	for i, repo := range repos {
+		repos[i] = fmt.Sprintf("%s (%d)", repo, c.RepoCommits[repo])
+	}
+	return strings.Join(repos, ", ")
+}
+
+// WriteHTML writes a standalone page with a table per user.
+func (rs Results) WriteHTML(w io.Writer) error {
+	return reportTemplate.Execute(w, rs)
+}
+
+// markdownEscape keeps s from breaking out of its table cell.
+var markdownEscape = strings.NewReplacer("|", "\\|", "\n", " ", "\r", "")
+
+// WriteMarkdown writes a GitHub-flavored Markdown table.
+func (rs Results) WriteMarkdown(w io.Writer) error {
+	for n, r := range rs {
+		if n > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if len(rs) > 1 {
+			if _, err := fmt.Fprintf(w, "## %s\n\n", markdownEscape.Replace(r.User)); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprint(w, "| Email | Name | Commits |\n| --- | --- | ---: |\n"); err != nil {
+			return err
+		}
+		for _, c := range r.Contacts {
+			_, err := fmt.Fprintf(w, "| %s | %s | %d |\n", markdownEscape.Replace(c.Email), markdownEscape.Replace(c.DisplayName()), c.Count)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// vcfEscape escapes the characters with a meaning in vCard values.
+var vcfEscape = strings.NewReplacer("\\", "\\\\", ",", "\\,", ";", "\\;", "\n", "\\n", "\r", "")
+
+// WriteVCF writes a vCard 3.0 per address, for address books. An address
+// found for several users gets one card.
+func (rs Results) WriteVCF(w io.Writer) error {
+	seen := make(map[string]bool)
+	for _, r := range rs {
+		for _, c := range r.Contacts {
+			if seen[c.Email] {
+				continue
+			}
+			seen[c.Email] = true
+
+			name := c.Name
+			if name == "" {
+				// Address books want a name; the local part is the best guess.
+				name, _, _ = strings.Cut(c.Email, "@")
+			}
+			card := "BEGIN:VCARD\r\nVERSION:3.0\r\n" +
+				vcfLine("FN:"+vcfEscape.Replace(name)) +
+				vcfLine("N:"+vcfName(name)) +
+				vcfNickname(c.Aliases) +
+				vcfLine("EMAIL;TYPE=INTERNET:"+vcfEscape.Replace(c.Email)) +
+				"END:VCARD\r\n"
+			if _, err := io.WriteString(w, card); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// vcfName guesses the structured N value of name: the last word as the
+// family name and the rest as given names.
+func vcfName(name string) string {
+	given, family := name, ""
+	if i := strings.LastIndexByte(name, ' '); i > 0 {
+		given, family = strings.TrimRight(name[:i], " ,"), name[i+1:]
+	}
+	return vcfEscape.Replace(family) + ";" + vcfEscape.Replace(given) + ";;;"
+}
+
+// vcfNickname returns the NICKNAME line listing aliases, "" without any.
+func vcfNickname(aliases []string) string {
+	if len(aliases) == 0 {
+		return ""
+	}
+	escaped := make([]string, len(aliases))
+	for i, a := range aliases {
+		escaped[i] = vcfEscape.Replace(a)
+	}
+	return vcfLine("NICKNAME:" + strings.Join(escaped, ","))
+}
+
+// vcfLine ends line with CRLF, folded into continuation lines of at most
+// 75 bytes as vCard asks, without splitting a UTF-8 sequence.
+func vcfLine(line string) string {
+	var b strings.Builder
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// The leading space of continuation lines counts too.
+		limit = 74
+	}
+	b.WriteString(line + "\r\n")
+	return b.String()
+}
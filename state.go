@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nottgy/github-sniffer/sniffer"
+)
+
+// configPath returns the path of the state file name in the
+// github-sniffer directory under the user config directory.
+func configPath(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "github-sniffer", name), nil
+}
+
+// stateMu serializes the updates of the state files, which the scans of
+// a batch make side by side.
+var stateMu sync.Mutex
+
+// writeState replaces the state file at p with b. It writes a temporary
+// file and renames it over p, so a reader never sees half a file. The
+// history holds every address found, so like the cache the files are
+// readable by the user alone, and so is their directory.
+func writeState(p string, b []byte) error {
+	dir := filepath.Dir(p)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	// Older versions made it readable by everyone.
+	if err := os.Chmod(dir, 0o700); err != nil {
+		return err
+	}
+	// CreateTemp makes the file 0600.
+	tmp, err := os.CreateTemp(dir, filepath.Base(p)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), p)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// lastRunPath is where the completion time of the previous scan of each
+// user is kept for -since-last-run.
+func lastRunPath() (string, error) {
+	return configPath("last-run.json")
+}
+
+// lastRunKey identifies user in the last-run file: the same name on
+// another forge or server is another account.
+func lastRunKey(user string) string {
+	base := opts.BaseURL
+	if base == "" {
+		base = map[string]string{
+			"github":    sniffer.DefaultBaseURL,
+			"gitlab":    sniffer.DefaultGitLabURL,
+			"bitbucket": sniffer.DefaultBitbucketURL,
+		}[opts.Provider]
+	}
+	return fmt.Sprintf("%s %s %s", opts.Provider, strings.TrimRight(base, "/"), user)
+}
+
+func readLastRuns() (map[string]time.Time, error) {
+	runs := map[string]time.Time{}
+	p, err := lastRunPath()
+	if err != nil {
+		return runs, err
+	}
+	b, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return runs, nil
+	}
+	if err != nil {
+		return runs, err
+	}
+	return runs, json.Unmarshal(b, &runs)
+}
+
+// loadLastRun returns when user was last scanned. The zero time means
+// there is no previous run and everything should be scanned.
+func loadLastRun(user string) (time.Time, error) {
+	runs, err := readLastRuns()
+	if t, ok := runs[lastRunKey(user)]; ok {
+		return t, err
+	}
+	if opts.Provider == "github" && opts.BaseURL == "" {
+		// Older versions kept github.com users by name alone.
+		return runs[user], err
+	}
+	return time.Time{}, err
+}
+
+func saveLastRun(user string, t time.Time) error {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	runs, err := readLastRuns()
+	if err != nil {
+		return err
+	}
+	runs[lastRunKey(user)] = t.UTC()
+
+	p, err := lastRunPath()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(runs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeState(p, b)
+}
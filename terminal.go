@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/muesli/termenv"
+)
+
+// saveTerminal records the state of the terminal on stdin before the UI
+// puts it in raw mode, and returns a func that puts it back: cooked
+// mode, a visible cursor and the main screen on out, where the UI draws.
+// Bubble Tea does so itself on the way out, and on panics in its own
+// goroutines; this is for everything else. Without a terminal there is
+// nothing to restore.
+func saveTerminal(out *os.File) func() {
+	fd := os.Stdin.Fd()
+	if !term.IsTerminal(fd) {
+		return func() {}
+	}
+	state, err := term.GetState(fd)
+	if err != nil {
+		return func() {}
+	}
+	return func() {
+		term.Restore(fd, state)
+		o := termenv.NewOutput(out)
+		o.ExitAltScreen()
+		o.ShowCursor()
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// theme holds the styles everything on screen is drawn with.
+type theme struct {
+	focused, blurred, subtle, err lipgloss.Style
+}
+
+func colored(c string) lipgloss.Style {
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(c))
+}
+
+var themes = map[string]theme{
+	"dark":  {focused: colored("205"), blurred: colored("240"), subtle: colored("244"), err: colored("196")},
+	"light": {focused: colored("125"), blurred: colored("243"), subtle: colored("238"), err: colored("160")},
+	// mono tells things apart by weight alone, for terminals where no
+	// color reads well.
+	"mono": {
+		focused: lipgloss.NewStyle().Bold(true),
+		blurred: lipgloss.NewStyle().Faint(true),
+		subtle:  lipgloss.NewStyle(),
+		err:     lipgloss.NewStyle().Bold(true).Underline(true),
+	},
+}
+
+// themeNames lists the themes for -theme, sorted.
+func themeNames() string {
+	var names []string
+	for name := range themes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ", ")
+}
+
+// applyTheme sets the styles to those of the theme called name. NO_COLOR
+// needs no handling here: lipgloss then renders every style plain.
+func applyTheme(name string) error {
+	t, ok := themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme %q, want one of %s", name, themeNames())
+	}
+	focusedStyle = t.focused
+	blurredStyle = t.blurred
+	cursorStyle = focusedStyle
+	helpStyle = blurredStyle
+	cursorModeHelpStyle = t.subtle
+	errorStyle = t.err
+
+	focusedButton = focusedStyle.Render("[ Check ]")
+	blurredButton = fmt.Sprintf("[ %s ]", blurredStyle.Render("Check"))
+	return nil
+}
+
+// styleList draws the title of l in the theme instead of the list
+// default.
+func styleList(l *list.Model) {
+	l.Styles.Title = focusedStyle.Bold(true).Padding(0, 1)
+}
+
+// themedDelegate returns the default list delegate with the selected item
+// in the theme.
+func themedDelegate() list.DefaultDelegate {
+	d := list.NewDefaultDelegate()
+	d.Styles.SelectedTitle = d.Styles.SelectedTitle.
+		Foreground(focusedStyle.GetForeground()).
+		BorderForeground(focusedStyle.GetForeground())
+	d.Styles.SelectedDesc = d.Styles.SelectedTitle.Inherit(helpStyle)
+	return d
+}
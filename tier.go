@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/nottgy/github-sniffer/sniffer"
+)
+
+// Without a token GitHub allows 60 requests an hour, so unless the flags
+// say otherwise scans go slower and stop at the first page of commits.
+const (
+	anonymousConcurrency = 2
+	anonymousMaxCommits  = 100
+)
+
+// anonymousNotice warns about scanning GitHub without a token.
+func anonymousNotice() string {
+	if opts.MaxCommits > 0 {
+		return fmt.Sprintf("running unauthenticated: 60 requests/hour, at most %d commits per repo. Pass -auth or set GH_TOKEN for 5000.", opts.MaxCommits)
+	}
+	return "running unauthenticated: 60 requests/hour. Pass -auth or set GH_TOKEN for 5000."
+}
+
+// tier says which rate limit applies: the warning without a token, the
+// limit of the token otherwise, "" when it can't tell. Other forges don't
+// report one.
+func tier() (text string, warn bool) {
+	switch {
+	case opts.Provider != "github":
+		return "", false
+	case opts.Auth == "":
+		return anonymousNotice(), true
+	}
+	b, err := sniffer.FetchBudget(context.Background(), opts)
+	if err != nil {
+		opts.Logger.Warn("could not fetch the rate limit", "err", err)
+		return "", false
+	}
+	return fmt.Sprintf("authenticated: %d requests/hour, %d left", b.Limit, b.Remaining), false
+}
+
+// tierMsg describes the rate limit the scans run under.
+type tierMsg string
+
+// fetchTier tells the input screen which rate limit applies.
+func fetchTier() tea.Msg {
+	text, warn := tier()
+	switch {
+	case text == "":
+		return nil
+	case warn:
+		return tierMsg(errorStyle.Render(text))
+	}
+	return tierMsg(helpStyle.Render(text))
+}